@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// launchMode is how the command of a file association is run.
+type launchMode int
+
+const (
+	launchForeground launchMode = iota // Suspend the UI and give the command the terminal
+	launchBackground                   // Detach the command and keep browsing
+	launchCapture                      // Wait for the command and show its output
+)
+
+func (l launchMode) String() string {
+	switch l {
+	case launchBackground:
+		return "background"
+	case launchCapture:
+		return "capture"
+	default:
+		return "foreground"
+	}
+}
+
+// parseLaunchMode returns the launch mode called name, foreground if name is empty.
+func parseLaunchMode(name string) (launchMode, error) {
+	switch name {
+	case "", "foreground":
+		return launchForeground, nil
+	case "background":
+		return launchBackground, nil
+	case "capture":
+		return launchCapture, nil
+	}
+	return 0, fmt.Errorf("expected \"foreground\", \"background\" or \"capture\", got %q", name)
+}
+
+// association maps files to the command that opens them on enter. Every
+// criterion that is set has to match; the first matching rule is used.
+type association struct {
+	Glob       string   // Shell pattern matched against the file name, such as "*.tar.*"
+	Extensions []string // File extensions without the dot, matched ignoring case
+	MIME       string   // Pattern matched against the type sniffed from the content, such as "image/*"
+	Command    string   // Shell command, see expandCommand for the placeholders
+	Mode       launchMode
+}
+
+// associations are the rules consulted when a file is opened. Files that
+// match none are opened by openFileCmd.
+var associations []association
+
+// matches reports whether the rule applies to f. mimeType is only called
+// when the rule asks for a MIME type, as it reads the file.
+func (a association) matches(f file, mimeType func() string) bool {
+	if a.Glob != "" {
+		if ok, _ := filepath.Match(a.Glob, f.Name); !ok {
+			return false
+		}
+	}
+	if len(a.Extensions) > 0 {
+		_, ext := splitExt(f)
+		ext = strings.TrimPrefix(ext, ".")
+		if ext == "" || !slices.ContainsFunc(a.Extensions, func(e string) bool {
+			return strings.EqualFold(strings.TrimPrefix(e, "."), ext)
+		}) {
+			return false
+		}
+	}
+	if a.MIME != "" {
+		if ok, _ := path.Match(a.MIME, mimeType()); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffMIME returns the MIME type of the file at path judged from its first
+// bytes, without parameters such as the charset.
+func sniffMIME(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return ""
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return strings.TrimSpace(mimeType)
+}
+
+// findAssociation returns the first rule for f.
+func findAssociation(f file) (association, bool) {
+	var sniffed *string
+	mimeType := func() string {
+		if sniffed == nil {
+			t := ""
+			if f.Mode.IsRegular() { // Reading a FIFO or device could block
+				t = sniffMIME(f.Path)
+			}
+			sniffed = &t
+		}
+		return *sniffed
+	}
+	for _, a := range associations {
+		if a.matches(f, mimeType) {
+			return a, true
+		}
+	}
+	return association{}, false
+}
+
+// shellQuote quotes s for use as a single word in sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commandContext is what the placeholders of a command refer to.
+type commandContext struct {
+	file     file   // %f, the file under the cursor
+	selected []file // %s, the file under the cursor if empty
+	dir      string // %d
+	otherDir string // %D, the folder of the other pane
+}
+
+// expandCommand fills in the placeholders of a command line: %f is the
+// file's path, %s the selected files (or the file if none are selected), %d
+// the folder, %D the other pane's folder and %% a percent sign. Paths are
+// quoted for the shell.
+func expandCommand(command string, ctx commandContext) string {
+	selected := ctx.selected
+	if len(selected) == 0 {
+		selected = []file{ctx.file}
+	}
+	var paths []string
+	for _, s := range selected {
+		paths = append(paths, shellQuote(s.Path))
+	}
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] != '%' || i+1 == len(command) {
+			b.WriteByte(command[i])
+			continue
+		}
+		i++
+		switch command[i] {
+		case 'f':
+			b.WriteString(shellQuote(ctx.file.Path))
+		case 'd':
+			b.WriteString(shellQuote(ctx.dir))
+		case 'D':
+			b.WriteString(shellQuote(ctx.otherDir))
+		case 's':
+			b.WriteString(strings.Join(paths, " "))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(command[i])
+		}
+	}
+	return b.String()
+}
+
+// runAssociationCmd runs the command of rule a on f.
+func runAssociationCmd(paneID int, a association, f file, selected []file) tea.Cmd {
+	ctx := commandContext{file: f, selected: selected, dir: filepath.Dir(f.Path)}
+	cmd := exec.Command("sh", "-c", expandCommand(a.Command, ctx))
+	cmd.Dir = ctx.dir
+	switch a.Mode {
+	case launchBackground:
+		return func() tea.Msg {
+			detach(cmd)
+			if err := cmd.Start(); err != nil {
+				return fileOpenedMsg{err: fmt.Errorf("%s: %w", a.Command, err)}
+			}
+			go cmd.Wait() // Reap the process whenever it exits
+			return fileOpenedMsg{}
+		}
+	case launchCapture:
+		return captureOutputCmd(paneID, cmd, a.Command, "")
+	default:
+		return runExternalCmd(paneID, f.Path, cmd, nil)
+	}
+}
+
+// captureOutputCmd runs cmd to the end and shows what it printed after
+// header. Errors are reported as coming from name.
+func captureOutputCmd(paneID int, cmd *exec.Cmd, name, header string) tea.Cmd {
+	return func() tea.Msg {
+		out := bytes.NewBufferString(header)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		err := cmd.Run()
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		return commandOutputMsg{paneID: paneID, output: out.String(), err: err}
+	}
+}
+
+// openCmd opens the file under the cursor with the first matching
+// association, or with openFileCmd if there is none.
+func (p pane) openCmd(f file) tea.Cmd {
+	if a, ok := findAssociation(f); ok {
+		return runAssociationCmd(p.id, a, f, getFilesFromSelected(p))
+	}
+	return openFileCmd(p.id, f.Path)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// renameCase is the case conversion applied to batch renamed names.
+type renameCase int
+
+const (
+	caseKeep renameCase = iota
+	caseLower
+	caseUpper
+	caseTitle
+)
+
+func (c renameCase) String() string {
+	switch c {
+	case caseLower:
+		return "lower"
+	case caseUpper:
+		return "upper"
+	case caseTitle:
+		return "title"
+	default:
+		return "keep"
+	}
+}
+
+// apply converts the case of name.
+func (c renameCase) apply(name string) string {
+	switch c {
+	case caseLower:
+		return strings.ToLower(name)
+	case caseUpper:
+		return strings.ToUpper(name)
+	case caseTitle:
+		// Upper-case the first letter of every word, lower-case the rest
+		r := []rune(name)
+		for i := range r {
+			if i == 0 || !unicode.IsLetter(r[i-1]) && !unicode.IsDigit(r[i-1]) && r[i-1] != '\'' {
+				r[i] = unicode.ToUpper(r[i])
+			} else {
+				r[i] = unicode.ToLower(r[i])
+			}
+		}
+		return string(r)
+	}
+	return name
+}
+
+// splitExt splits a file name into its base name and extension (with the dot).
+// Folders and dot files without another dot have no extension.
+func splitExt(f file) (base, ext string) {
+	ext = filepath.Ext(f.Name)
+	if f.IsDir || ext == f.Name {
+		return f.Name, ""
+	}
+	return strings.TrimSuffix(f.Name, ext), ext
+}
+
+// Fields of the batch rename dialog.
+const (
+	fieldTemplate = iota
+	fieldSearch
+	fieldReplace
+	fieldCount
+)
+
+// plannedRename is the new name worked out for one file of a batch rename.
+type plannedRename struct {
+	file    file
+	newName string
+	problem string // Why the file can't get its new name, empty if it can
+}
+
+// changed reports whether the file gets a different name.
+func (r plannedRename) changed() bool {
+	return r.newName != r.file.Name
+}
+
+// batchRename is the state of the multi-rename dialog.
+type batchRename struct {
+	paneID   int
+	dir      string
+	files    []file
+	existing map[string]bool // Names of everything in the folder, hidden or not, to detect collisions
+	inputs   [fieldCount]textInput
+	focus    int
+	literal  bool // Search for plain text instead of a regular expression
+	caseMode renameCase
+	plan     []plannedRename
+	err      error // Invalid search expression
+	scrollY  int
+}
+
+// newBatchRename opens the dialog for renaming files listed in p.
+func newBatchRename(p pane, files []file) *batchRename {
+	b := &batchRename{
+		paneID:   p.id,
+		dir:      p.path,
+		files:    files,
+		existing: folderNames(p.path),
+	}
+	b.inputs[fieldTemplate] = newTextInput("[N][E]")
+	b.inputs[fieldSearch] = newTextInput("")
+	b.inputs[fieldReplace] = newTextInput("")
+	b.replan()
+	return b
+}
+
+// counterWidth returns the number of digits of the largest counter value.
+func (b *batchRename) counterWidth() int {
+	return len(strconv.Itoa(len(b.files)))
+}
+
+// expandTemplate fills the placeholders of template for f, the i-th file:
+// [N] base name, [E] extension with its dot, [C] counter and [D] modification date.
+func (b *batchRename) expandTemplate(template string, i int, f file) string {
+	base, ext := splitExt(f)
+	return strings.NewReplacer(
+		"[N]", base,
+		"[E]", ext,
+		"[C]", fmt.Sprintf("%0*d", b.counterWidth(), i+1),
+		"[D]", f.ModTime.Format("2006-01-02"),
+	).Replace(template)
+}
+
+// replan works out the new names from the dialog fields and checks them for collisions.
+func (b *batchRename) replan() {
+	b.err = nil
+	var search *regexp.Regexp
+	if expr := b.inputs[fieldSearch].Value(); expr != "" {
+		if b.literal {
+			expr = regexp.QuoteMeta(expr)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			b.err = err
+		} else {
+			search = re
+		}
+	}
+	replace := b.inputs[fieldReplace].Value()
+	if b.literal {
+		replace = strings.ReplaceAll(replace, "$", "$$")
+	}
+
+	b.plan = make([]plannedRename, len(b.files))
+	counts := make(map[string]int)
+	for i, f := range b.files {
+		name := b.expandTemplate(b.inputs[fieldTemplate].Value(), i, f)
+		if search != nil {
+			name = search.ReplaceAllString(name, replace)
+		}
+		name = b.caseMode.apply(name)
+		b.plan[i] = plannedRename{file: f, newName: name}
+		counts[name]++
+	}
+
+	// Names given up by files of the batch are free for the others to take
+	leaving := make(map[string]bool)
+	for _, r := range b.plan {
+		if r.changed() {
+			leaving[r.file.Name] = true
+		}
+	}
+	for i := range b.plan {
+		r := &b.plan[i]
+		switch {
+		case r.newName == "" || r.newName == "." || r.newName == ".." || strings.ContainsRune(r.newName, filepath.Separator):
+			r.problem = "invalid name"
+		case counts[r.newName] > 1:
+			r.problem = "duplicate name"
+		case r.changed() && b.existing[r.newName] && !leaving[r.newName]:
+			r.problem = "already exists"
+		}
+	}
+}
+
+// problems returns the number of files that can't be renamed as planned.
+func (b *batchRename) problems() int {
+	n := 0
+	for _, r := range b.plan {
+		if r.problem != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// renames returns the from/to paths of the files whose name changes.
+func (b *batchRename) renames() []journalItem {
+	var items []journalItem
+	for _, r := range b.plan {
+		if r.changed() {
+			items = append(items, journalItem{From: r.file.Path, To: filepath.Join(b.dir, r.newName)})
+		}
+	}
+	return items
+}
+
+// update handles a key in the dialog, bound to action; visible is the number
+// of preview rows shown.
+func (b *batchRename) update(msg tea.KeyMsg, action string, visible int) {
+	switch {
+	case action == "prompt_next_field":
+		b.focus = (b.focus + 1) % fieldCount
+	case action == "prompt_prev_field":
+		b.focus = (b.focus + fieldCount - 1) % fieldCount
+	case action == "prompt_regex":
+		b.literal = !b.literal
+		b.replan()
+	case action == "prompt_option":
+		b.caseMode = (b.caseMode + 1) % (caseTitle + 1)
+		b.replan()
+	case msg.String() == "up":
+		b.scrollY--
+	case msg.String() == "down":
+		b.scrollY++
+	case msg.String() == "pgup":
+		b.scrollY -= visible
+	case msg.String() == "pgdown":
+		b.scrollY += visible
+	default:
+		if b.inputs[b.focus].update(msg) {
+			b.replan()
+		}
+	}
+	b.scrollY = clamp(b.scrollY, 0, max(0, len(b.plan)-visible))
+}
+
+// orderRenames turns renames into steps that never overwrite a file of the
+// batch before it has moved away. Cycles such as a swap go through a
+// temporary name in the same folder.
+func orderRenames(renames []journalItem) []journalItem {
+	pending := append([]journalItem(nil), renames...)
+	var steps []journalItem
+	for temp := 0; len(pending) > 0; {
+		sources := make(map[string]bool)
+		for _, r := range pending {
+			sources[r.From] = true
+		}
+		var blocked []journalItem
+		for _, r := range pending {
+			if sources[r.To] {
+				blocked = append(blocked, r)
+				continue
+			}
+			steps = append(steps, r)
+			delete(sources, r.From)
+		}
+		if len(blocked) == len(pending) {
+			// Only cycles are left: park one file to break its cycle
+			r := &blocked[0]
+			var parked string
+			for {
+				temp++
+				parked = filepath.Join(filepath.Dir(r.From), fmt.Sprintf(".twin-rename-%d-%d", os.Getpid(), temp))
+				if _, err := os.Lstat(parked); err != nil {
+					break
+				}
+			}
+			steps = append(steps, journalItem{From: r.From, To: parked})
+			r.From = parked
+		}
+		pending = blocked
+	}
+	return steps
+}
+
+// applyRenames renames files in a safe order. It stops at the first failure
+// and returns the steps that were done, so they can still be undone.
+func applyRenames(renames []journalItem) ([]journalItem, error) {
+	var done []journalItem
+	for _, step := range orderRenames(renames) {
+		if _, err := os.Lstat(step.To); err == nil {
+			return done, fmt.Errorf("failed to rename %s: %s already exists", filepath.Base(step.From), filepath.Base(step.To))
+		}
+		if err := os.Rename(step.From, step.To); err != nil {
+			return done, fmt.Errorf("failed to rename %s: %w", filepath.Base(step.From), err)
+		}
+		done = append(done, step)
+	}
+	return done, nil
+}
+
+// batchRenameCmd renames files as planned by the multi-rename dialog.
+func batchRenameCmd(paneID int, renames []journalItem) tea.Cmd {
+	return func() tea.Msg {
+		done, err := applyRenames(renames)
+		return batchRenamedMsg{paneID: paneID, done: done, err: err}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pauseScript runs after a command line given the terminal, so its output
+// can be read before twin takes the screen back.
+const pauseScript = `
+status=$?
+printf '\n[exit status %d] Press enter to return to twin ' "$status"
+read _`
+
+// commandContext returns what the placeholders of a command refer to in the
+// active pane. The ".." entry doesn't count as a file.
+func (m *model) commandContext() commandContext {
+	activePane, other := &m.leftPane, &m.rightPane
+	if m.rightPane.active {
+		activePane, other = other, activePane
+	}
+	ctx := commandContext{
+		selected: getFilesFromSelected(*activePane),
+		dir:      activePane.path,
+		otherDir: other.path,
+	}
+	if activePane.cursor < len(activePane.files) && activePane.files[activePane.cursor].Name != ".." {
+		ctx.file = activePane.files[activePane.cursor]
+	}
+	return ctx
+}
+
+// runCommandLine runs the command typed on the command line in the active
+// pane's folder. With terminal set, twin is suspended while it runs;
+// otherwise its output is shown in the preview. Both panes are reloaded
+// afterwards.
+func (m model) runCommandLine(terminal bool) (tea.Model, tea.Cmd) {
+	line := strings.TrimSpace(m.prompt.input.Value())
+	m.prompt.input.commit()
+	m.prompt = nil
+	m.promptErr = nil
+	if line == "" {
+		return m, nil
+	}
+
+	activePane := m.activePane()
+	ctx := m.commandContext()
+	expanded := expandCommand(line, ctx)
+	if terminal {
+		cmd := exec.Command("sh", "-c", expanded+pauseScript)
+		cmd.Dir = activePane.path
+		return m, runExternalCmd(activePane.id, ctx.file.Path, cmd, nil)
+	}
+	cmd := exec.Command("sh", "-c", expanded)
+	cmd.Dir = activePane.path
+	return m, captureOutputCmd(activePane.id, cmd, line, "$ "+line+"\n\n")
+}
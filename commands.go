@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -16,16 +17,50 @@ import (
 
 // Commands
 func (p pane) loadDirectoryCmd(focusPath string) tea.Cmd {
+	switch {
+	case p.listing == listingTrash:
+		return p.loadTrashCmd()
+	case p.listing == listingFind && p.finder != nil:
+		return nil // Reloaded once the search is over
+	case p.listing == listingFind:
+		return p.loadFoundCmd(focusPath)
+	}
 	return func() tea.Msg {
 		files, err := readDirectory(p.path)
-		return directoryLoadedMsg{paneID: p.id, files: files, err: err, focusPath: focusPath}
+		hidden := 0
+		if err == nil {
+			var rules []ignoreRule
+			if useGitignore && !p.showHidden {
+				rules = gitignoreRules(p.path)
+			}
+			files, hidden = p.arrange(files, rules)
+		}
+		return directoryLoadedMsg{paneID: p.id, files: files, hidden: hidden, err: err, focusPath: focusPath}
+	}
+}
+
+func (p pane) loadTrashCmd() tea.Cmd {
+	return func() tea.Msg {
+		files, byPath := trashListing(listTrash())
+		return directoryLoadedMsg{paneID: p.id, files: files, trash: byPath}
 	}
 }
 
-func openFileCmd(path string) tea.Cmd {
+// openFileCmd opens path with the desktop's default application, or in the
+// pager when there is no graphical session (such as over SSH).
+func openFileCmd(paneID int, path string) tea.Cmd {
+	if _, err := exec.LookPath("xdg-open"); err != nil || !hasDisplay() {
+		return pageFileCmd(paneID, path)
+	}
 	return func() tea.Msg {
-		cmd := exec.Command("xdg-open", path)
-		err := cmd.Run()
+		out, err := exec.Command("xdg-open", path).CombinedOutput()
+		if err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				err = fmt.Errorf("xdg-open: %s", msg)
+			} else {
+				err = fmt.Errorf("xdg-open: %w", err)
+			}
+		}
 		return fileOpenedMsg{err: err}
 	}
 }
@@ -37,112 +72,195 @@ func createFolderCmd(path string) tea.Cmd {
 	}
 }
 
+// renameFileCmd renames from to to, refusing to replace another existing file.
+func renameFileCmd(paneID int, from, to string) tea.Cmd {
+	return func() tea.Msg {
+		if info, err := os.Lstat(to); err == nil {
+			// Allow case-only renames on case-insensitive filesystems
+			if src, err := os.Lstat(from); err != nil || !os.SameFile(src, info) {
+				return fileRenamedMsg{paneID: paneID, from: from, to: to, err: fmt.Errorf("%s already exists", filepath.Base(to))}
+			}
+		}
+		err := os.Rename(from, to)
+		return fileRenamedMsg{paneID: paneID, from: from, to: to, err: err}
+	}
+}
+
 func deleteFileCmd(f file) tea.Cmd {
 	return deleteFilesCmd([]file{f})
 }
 
 func deleteFilesCmd(files []file) tea.Cmd {
+	return func() tea.Msg {
+		return jobPreparedMsg{job: newJob(jobDelete, files, "")}
+	}
+}
+
+func trashFilesCmd(files []file) tea.Cmd {
+	return func() tea.Msg {
+		return jobPreparedMsg{job: newJob(jobTrash, files, "")}
+	}
+}
+
+func restoreTrashCmd(entries []trashEntry) tea.Cmd {
 	return func() tea.Msg {
 		var errors []string
-		for _, f := range files {
-			var err error
-			if f.IsDir {
-				err = os.RemoveAll(f.Path)
-			} else {
-				err = os.Remove(f.Path)
-			}
-			if err != nil {
-				errors = append(errors, fmt.Sprintf("%s: %v", f.Name, err))
+		for _, e := range entries {
+			if err := restoreTrashed(e); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", filepath.Base(e.OriginalPath), err))
 			}
 		}
 		if len(errors) > 0 {
-			return fileDeletedMsg{err: fmt.Errorf("failed to delete: %s", strings.Join(errors, ", "))}
+			return trashUpdatedMsg{err: fmt.Errorf("failed to restore: %s", strings.Join(errors, ", "))}
 		}
-		return fileDeletedMsg{err: nil}
+		return trashUpdatedMsg{}
 	}
 }
 
-func copyFilesCmd(sourceFiles []file, destPath string, force bool) tea.Cmd {
+func purgeTrashCmd(entries []trashEntry) tea.Cmd {
 	return func() tea.Msg {
-		if !force {
-			var conflicts []fileConflict
-			for _, srcFile := range sourceFiles {
-				destFilePath := filepath.Join(destPath, srcFile.Name)
-				if _, err := os.Stat(destFilePath); !os.IsNotExist(err) {
-					conflicts = append(conflicts, fileConflict{Source: srcFile, Destination: destFilePath})
-				}
-			}
-			if len(conflicts) > 0 {
-				return fileConflictMsg{Conflicts: conflicts}
+		var errors []string
+		for _, e := range entries {
+			if err := purgeTrashed(e); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", filepath.Base(e.OriginalPath), err))
 			}
 		}
+		if len(errors) > 0 {
+			return trashUpdatedMsg{err: fmt.Errorf("failed to delete: %s", strings.Join(errors, ", "))}
+		}
+		return trashUpdatedMsg{}
+	}
+}
+
+func copyFilesCmd(sourceFiles []file, destPath string, dereference bool) tea.Cmd {
+	return prepareTransferCmd(jobCopy, sourceFiles, destPath, dereference)
+}
+
+func moveFilesCmd(sourceFiles []file, destPath string) tea.Cmd {
+	return prepareTransferCmd(jobMove, sourceFiles, destPath, false)
+}
 
+// prepareTransferCmd creates a copy or move job for sourceFiles, recording
+// which of them would overwrite something in destPath. Files from different
+// folders, such as search results, can share a name: all but the first of
+// them are conflicts too. Files that are their own destination, as when both
+// panes show the same folder, are left out: copying one would empty it. So
+// are folders destPath is inside of, which would be copied into themselves
+// without end.
+func prepareTransferCmd(kind jobKind, sourceFiles []file, destPath string, dereference bool) tea.Cmd {
+	return func() tea.Msg {
+		j := newJob(kind, nil, destPath)
+		j.dereference = dereference
+		names := make(map[string]struct{})
+		var same, inside []string
 		for _, srcFile := range sourceFiles {
 			destFilePath := filepath.Join(destPath, srcFile.Name)
-			if srcFile.IsDir {
-				err := copyDir(srcFile.Path, destFilePath)
-				if err != nil {
-					return fileOperationMsg{err: fmt.Errorf("failed to copy directory %s: %w", srcFile.Name, err)}
-				}
+			if sameFile(srcFile.Path, destFilePath) {
+				same = append(same, srcFile.Name)
+				continue
+			}
+			// A symlink is only a way into its folder when the copy follows it
+			isLink := srcFile.Mode&os.ModeSymlink != 0
+			if (srcFile.IsDir || isLink && dereference && kind == jobCopy) && isInside(destPath, srcFile.Path) {
+				inside = append(inside, srcFile.Name)
+				continue
+			}
+			_, taken := names[srcFile.Name]
+			names[srcFile.Name] = struct{}{}
+			if _, err := os.Stat(destFilePath); taken || !os.IsNotExist(err) {
+				j.conflicts = append(j.conflicts, fileConflict{Source: srcFile, Destination: destFilePath})
 			} else {
-				err := copyFile(srcFile.Path, destFilePath)
-				if err != nil {
-					return fileOperationMsg{err: fmt.Errorf("failed to copy file %s: %w", srcFile.Name, err)}
-				}
+				j.files = append(j.files, srcFile)
 			}
 		}
-		return fileOperationMsg{err: nil}
+		verb := strings.ToLower(kind.String())
+		var problems []string
+		if len(same) > 0 {
+			problems = append(problems, fmt.Sprintf("can't %s a file onto itself: %s", verb, strings.Join(same, ", ")))
+		}
+		if len(inside) > 0 {
+			problems = append(problems, fmt.Sprintf("can't %s a folder into itself: %s", verb, strings.Join(inside, ", ")))
+		}
+		msg := jobPreparedMsg{job: j}
+		if len(problems) > 0 {
+			msg.err = errors.New(strings.Join(problems, "; "))
+		}
+		return msg
 	}
 }
 
-func moveFilesCmd(sourceFiles []file, destPath string, force bool) tea.Cmd {
-	return func() tea.Msg {
-		if !force {
-			var conflicts []fileConflict
-			for _, srcFile := range sourceFiles {
-				destFilePath := filepath.Join(destPath, srcFile.Name)
-				if _, err := os.Stat(destFilePath); !os.IsNotExist(err) {
-					conflicts = append(conflicts, fileConflict{Source: srcFile, Destination: destFilePath})
-				}
-			}
-			if len(conflicts) > 0 {
-				return fileConflictMsg{Conflicts: conflicts}
-			}
-		}
+// isInside reports whether path is the folder dir or lies inside it, once
+// symlinks are resolved.
+func isInside(path, dir string) bool {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
-		for _, srcFile := range sourceFiles {
-			destFilePath := filepath.Join(destPath, srcFile.Name)
-			err := os.Rename(srcFile.Path, destFilePath)
-			if err != nil {
-				return fileOperationMsg{err: fmt.Errorf("failed to move %s: %w", srcFile.Name, err)}
-			}
-		}
-		return fileOperationMsg{err: nil}
+// sameFile reports whether the paths name the same file, through hard links
+// as well.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
 	}
+	infoA, errA := os.Lstat(a)
+	infoB, errB := os.Lstat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
 }
 
-func previewFileCmd(path string) tea.Cmd {
+// isBinary reports whether content is something other than text: it isn't
+// valid UTF-8 or holds NUL bytes.
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.Contains(content, []byte{0})
+}
+
+// previewFileCmd reads path for the preview. Large files are cut short, but
+// past line, counted from 1, when it is set: a content search's match is
+// always shown.
+func previewFileCmd(path string, line int) tea.Cmd {
 	return func() tea.Msg {
 		content, err := os.ReadFile(path)
 		if err != nil {
 			return previewReadyMsg{Err: fmt.Errorf("could not read file: %w", err)}
 		}
 
-		// Basic check for binary content
-		if !utf8.Valid(content) || bytes.Contains(content, []byte{0}) {
+		if isBinary(content) {
 			return previewReadyMsg{Content: fmt.Sprintf("--- Binary file: %s ---", filepath.Base(path))}
 		}
 
 		// Limit preview size
 		const maxPreviewSize = 1024 * 100 // 100KB
-		if len(content) > maxPreviewSize {
-			return previewReadyMsg{Content: fmt.Sprintf("--- File too large for preview (%s), showing first %d bytes ---\n%s", filepath.Base(path), maxPreviewSize, content[:maxPreviewSize])}
+		size := maxPreviewSize
+		if line > 0 {
+			size = max(size, lineEnd(content, line)+maxPreviewSize)
+		}
+		if len(content) > size {
+			return previewReadyMsg{Content: fmt.Sprintf("--- File too large for preview (%s), showing first %d bytes ---\n%s", filepath.Base(path), size, content[:size]), headerLines: 1}
 		}
 
 		return previewReadyMsg{Content: string(content)}
 	}
 }
 
+// lineEnd returns the offset just past line n, counted from 1, of content, or
+// its length when it has fewer lines.
+func lineEnd(content []byte, n int) int {
+	end := 0
+	for ; n > 0; n-- {
+		i := bytes.IndexByte(content[end:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		end += i + 1
+	}
+	return end
+}
+
 func copyToClipboardCmd(text string) tea.Cmd {
 	return func() tea.Msg {
 		err := clipboard.WriteAll(text)
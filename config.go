@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the user's configuration, read from config.toml.
+type Config struct {
+	Theme      string              `toml:"theme"` // "auto" or the name of a bundled theme
+	ShowHidden bool                `toml:"show_hidden"`
+	Ignore     []string            `toml:"ignore"`    // Names hidden along with dot files, as globs
+	Gitignore  bool                `toml:"gitignore"` // Hide what .gitignore files ignore along with dot files
+	Search     string              `toml:"search"`    // How type-ahead search matches names: fuzzy or prefix
+	Editors    []string            `toml:"editors"`   // Tried in order when $VISUAL and $EDITOR aren't set
+	Pagers     []string            `toml:"pagers"`    // Tried in order when $PAGER isn't set
+	Paths      PathsConfig         `toml:"paths"`
+	Sort       SortConfig          `toml:"sort"`
+	View       ViewConfig          `toml:"view"`
+	Confirm    ConfirmConfig       `toml:"confirm"`
+	Keys       map[string][]string `toml:"keys"`   // Bindings of each command, replacing its default ones
+	Colors     Colors              `toml:"colors"` // Colors replacing the theme's
+
+	Open    []OpenRule     `toml:"open"`
+	Actions []CustomAction `toml:"actions"`
+}
+
+// PathsConfig holds the folders the panes start in. Empty means the working directory.
+type PathsConfig struct {
+	Left  string `toml:"left"`
+	Right string `toml:"right"`
+}
+
+// SortConfig is the order panes start with.
+type SortConfig struct {
+	By         string `toml:"by"` // name, natural, extension, size, mtime or unsorted
+	Reverse    bool   `toml:"reverse"`
+	DirsFirst  bool   `toml:"dirs_first"`
+	IgnoreCase bool   `toml:"ignore_case"`
+}
+
+// ViewConfig is how panes list their files at start-up.
+type ViewConfig struct {
+	Mode         string   `toml:"mode"`          // brief, full or custom
+	BriefColumns int      `toml:"brief_columns"` // Columns of the brief mode, 0 to fit the pane
+	Columns      []string `toml:"columns"`       // Columns of the custom mode
+}
+
+// ConfirmConfig decides which operations ask before going ahead.
+type ConfirmConfig struct {
+	Trash     bool   `toml:"trash"`     // Ask before moving files to the trash
+	Delete    bool   `toml:"delete"`    // Ask before deleting files for good
+	Overwrite string `toml:"overwrite"` // "ask", "overwrite" or "skip" when a copy or move meets an existing file
+}
+
+// OpenRule is a file association as written in the config file.
+type OpenRule struct {
+	Glob       string   `toml:"glob,omitempty"`
+	Extensions []string `toml:"extensions,omitempty"`
+	MIME       string   `toml:"mime,omitempty"`
+	Command    string   `toml:"command"`
+	Mode       string   `toml:"mode,omitempty"` // "foreground" (the default), "background" or "capture"
+}
+
+// defaultConfig returns the configuration used when there is no config file.
+func defaultConfig() Config {
+	c := Config{
+		Theme:      "auto",
+		ShowHidden: true,
+		Search:     "fuzzy",
+		Editors:    editorFallbacks,
+		Pagers:     pagerFallbacks,
+		Sort:       SortConfig{By: "name", DirsFirst: true},
+		View:       ViewConfig{Mode: "brief", Columns: []string{"name", "size", "mtime"}},
+		Confirm:    ConfirmConfig{Trash: true, Delete: true, Overwrite: "ask"},
+		Keys:       make(map[string][]string),
+	}
+	for _, a := range DefaultKeyMap().actions {
+		c.Keys[a.Cmd] = a.Keys
+	}
+	return c
+}
+
+// configPath returns $XDG_CONFIG_HOME/twin/config.toml.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "twin", "config.toml"), nil
+}
+
+// loadConfig reads the config file at path on top of the defaults. A missing
+// file isn't an error. Every problem found is reported, naming its key.
+func loadConfig(path string) (Config, error) {
+	c := defaultConfig()
+	md, err := toml.DecodeFile(path, &c)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultConfig(), nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+
+	c.Paths.Left = expandHome(c.Paths.Left)
+	c.Paths.Right = expandHome(c.Paths.Right)
+
+	var errs []error
+	for _, key := range md.Undecoded() {
+		errs = append(errs, fmt.Errorf("%s: unknown key", key))
+	}
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		return c, fmt.Errorf("%s:\n%w", path, errors.Join(errs...))
+	}
+	return c, nil
+}
+
+// colorPattern matches the colors lipgloss understands.
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|[0-9]|[1-9][0-9]|1[0-9][0-9]|2[0-4][0-9]|25[0-5])$`)
+
+// validate checks the values the types can't, such as command names and colors.
+func (c Config) validate() []error {
+	var errs []error
+
+	km := c.keyMap()
+	var cmds []string
+	for cmd := range c.Keys {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	for _, cmd := range cmds {
+		switch _, ok := km.find(cmd); {
+		case !ok:
+			errs = append(errs, fmt.Errorf("keys.%s: unknown command", cmd))
+		case slices.ContainsFunc(c.Keys[cmd], func(k string) bool { return strings.TrimSpace(k) == "" }):
+			errs = append(errs, fmt.Errorf("keys.%s: empty key", cmd))
+		}
+	}
+	errs = append(errs, c.keyMap().conflicts()...)
+
+	for _, field := range c.Colors.fields() {
+		if *field.value != "" && !colorPattern.MatchString(*field.value) {
+			errs = append(errs, fmt.Errorf("colors.%s: invalid color %q, expected 0-255 or #rrggbb", field.name, *field.value))
+		}
+	}
+
+	if _, ok := findTheme(c.Theme); !ok && c.Theme != "auto" {
+		errs = append(errs, fmt.Errorf("theme: unknown theme %q", c.Theme))
+	}
+
+	if _, err := parseSortKey(c.Sort.By); err != nil {
+		errs = append(errs, fmt.Errorf("sort.by: %w", err))
+	}
+
+	if _, err := parseSearchMode(c.Search); err != nil {
+		errs = append(errs, fmt.Errorf("search: %w", err))
+	}
+
+	if _, err := parseListMode(c.View.Mode); err != nil {
+		errs = append(errs, fmt.Errorf("view.mode: %w", err))
+	}
+	if c.View.BriefColumns < 0 {
+		errs = append(errs, fmt.Errorf("view.brief_columns: expected 0 or more, got %d", c.View.BriefColumns))
+	}
+	for _, name := range c.View.Columns {
+		if _, err := parseColumn(name); err != nil {
+			errs = append(errs, fmt.Errorf("view.columns: %w", err))
+		}
+	}
+	if !slices.Contains(c.View.Columns, "name") {
+		errs = append(errs, errors.New("view.columns: the name column is missing"))
+	}
+
+	switch c.Confirm.Overwrite {
+	case "ask", "overwrite", "skip":
+	default:
+		errs = append(errs, fmt.Errorf("confirm.overwrite: expected \"ask\", \"overwrite\" or \"skip\", got %q", c.Confirm.Overwrite))
+	}
+
+	for _, p := range []struct {
+		key  string
+		path string
+	}{{"paths.left", c.Paths.Left}, {"paths.right", c.Paths.Right}} {
+		if p.path == "" {
+			continue
+		}
+		if info, err := os.Stat(p.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.key, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: %s is not a folder", p.key, p.path))
+		}
+	}
+
+	builtIn := DefaultKeyMap()
+	names := make(map[string]bool)
+	for i, a := range c.Actions {
+		key := fmt.Sprintf("actions[%d]", i)
+		switch _, ok := builtIn.find(a.Name); {
+		case strings.TrimSpace(a.Name) == "":
+			errs = append(errs, fmt.Errorf("%s.name: missing", key))
+		case ok || names[a.Name]:
+			errs = append(errs, fmt.Errorf("%s.name: %q is already a command", key, a.Name))
+		}
+		names[a.Name] = true
+		if a.Command == "" {
+			errs = append(errs, fmt.Errorf("%s.command: missing", key))
+		}
+		if slices.ContainsFunc(a.Keys, func(k string) bool { return strings.TrimSpace(k) == "" }) {
+			errs = append(errs, fmt.Errorf("%s.keys: empty key", key))
+		}
+		if _, err := parseLaunchMode(a.Mode); err != nil {
+			errs = append(errs, fmt.Errorf("%s.mode: %w", key, err))
+		}
+	}
+
+	for i, glob := range c.Ignore {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			errs = append(errs, fmt.Errorf("ignore[%d]: %w", i, err))
+		}
+	}
+
+	for i, rule := range c.Open {
+		key := fmt.Sprintf("open[%d]", i)
+		if rule.Command == "" {
+			errs = append(errs, fmt.Errorf("%s.command: missing", key))
+		}
+		if rule.Glob == "" && len(rule.Extensions) == 0 && rule.MIME == "" {
+			errs = append(errs, fmt.Errorf("%s: needs a glob, extensions or mime to match files", key))
+		}
+		if _, err := filepath.Match(rule.Glob, ""); err != nil {
+			errs = append(errs, fmt.Errorf("%s.glob: %w", key, err))
+		}
+		if _, err := parseLaunchMode(rule.Mode); err != nil {
+			errs = append(errs, fmt.Errorf("%s.mode: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// apply puts the settings that live outside the model into effect.
+func (c Config) apply() {
+	editorFallbacks = c.Editors
+	pagerFallbacks = c.Pagers
+	ignoreGlobs = c.Ignore
+	useGitignore = c.Gitignore
+	typeAheadSearch, _ = parseSearchMode(c.Search)
+	associations = nil
+	for _, rule := range c.Open {
+		mode, _ := parseLaunchMode(rule.Mode)
+		associations = append(associations, association{
+			Glob:       rule.Glob,
+			Extensions: rule.Extensions,
+			MIME:       rule.MIME,
+			Command:    rule.Command,
+			Mode:       mode,
+		})
+	}
+}
+
+// keyMap returns the default key map with the custom actions and the
+// configured bindings. An empty list unbinds a command.
+func (c Config) keyMap() KeyMap {
+	km := DefaultKeyMap()
+	for _, a := range c.Actions {
+		km.actions = append(km.actions, a.action())
+	}
+	for cmd, keys := range c.Keys {
+		if a, ok := km.find(cmd); ok {
+			a.Keys = keys
+		}
+	}
+	return km
+}
+
+// sortOrder returns the order panes start with.
+func (c Config) sortOrder() sortOrder {
+	by, _ := parseSortKey(c.Sort.By)
+	return sortOrder{by: by, reverse: c.Sort.Reverse, dirsFirst: c.Sort.DirsFirst, ignoreCase: c.Sort.IgnoreCase}
+}
+
+// listLayout returns how panes start listing their files.
+func (c Config) listLayout() listLayout {
+	mode, _ := parseListMode(c.View.Mode)
+	l := listLayout{mode: mode, briefColumns: c.View.BriefColumns}
+	for _, name := range c.View.Columns {
+		if col, err := parseColumn(name); err == nil {
+			l.columns = append(l.columns, col)
+		}
+	}
+	return l
+}
+
+// write prints the configuration as TOML.
+func (c Config) write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// CustomAction is a command of the user's own, declared in the config file.
+type CustomAction struct {
+	Name        string   `toml:"name"`                  // Command name, as used in [keys]
+	Label       string   `toml:"label,omitempty"`       // Name for the hints bar, the command name if empty
+	Description string   `toml:"description,omitempty"` // Shown in the command palette
+	Keys        []string `toml:"keys,omitempty"`
+	Command     string   `toml:"command"`           // Shell command, with the placeholders of the command line
+	Mode        string   `toml:"mode,omitempty"`    // "foreground" (the default), "background" or "capture"
+	Confirm     bool     `toml:"confirm,omitempty"` // Ask before running it
+	Refresh     bool     `toml:"refresh,omitempty"` // Reload both panes once it is done
+}
+
+// action returns the registry entry of c.
+func (c CustomAction) action() *Action {
+	label := c.Label
+	if label == "" {
+		label = c.Name
+	}
+	return &Action{Cmd: c.Name, Label: label, Help: c.Description, Mode: modePane, Keys: c.Keys}
+}
+
+// pendingAction is a custom action waiting for confirmation.
+type pendingAction struct {
+	label string
+	count int // Number of files it runs on
+	run   tea.Cmd
+}
+
+// customActionCmd runs c on the files of the active pane, in its folder.
+func (m *model) customActionCmd(c CustomAction) tea.Cmd {
+	activePane := m.activePane()
+	ctx := m.commandContext()
+	cmd := exec.Command("sh", "-c", expandCommand(c.Command, ctx))
+	cmd.Dir = activePane.path
+	done := actionDoneMsg{paneID: activePane.id, focusPath: ctx.file.Path, refresh: c.Refresh}
+
+	switch mode, _ := parseLaunchMode(c.Mode); mode {
+	case launchForeground:
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			if err != nil {
+				done.err = fmt.Errorf("%s: %w", c.Name, err)
+			}
+			return done
+		})
+	default:
+		// Background actions run like captured ones, but only their errors are shown
+		capture := mode == launchCapture
+		return func() tea.Msg {
+			var out bytes.Buffer
+			cmd.Stdout = &out
+			cmd.Stderr = &out
+			if err := cmd.Run(); err != nil {
+				if msg := strings.TrimSpace(out.String()); msg != "" && !capture {
+					err = fmt.Errorf("%w: %s", err, lastLine(msg))
+				}
+				done.err = fmt.Errorf("%s: %w", c.Name, err)
+			}
+			done.output, done.show = out.String(), capture
+			return done
+		}
+	}
+}
+
+// lastLine returns the last line of s, which tends to hold the error of a
+// failed command.
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\n")+1:]
+}
+
+// runCustomAction runs c, after asking if it wants confirmation.
+func (m *model) runCustomAction(c CustomAction) tea.Cmd {
+	run := m.customActionCmd(c)
+	if !c.Confirm {
+		return run
+	}
+	count := len(getFilesFromSelected(*m.activePane()))
+	m.pendingAction = &pendingAction{label: c.action().Label, count: max(count, 1), run: run}
+	return nil
+}
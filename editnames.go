@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editNamesHeader explains the file handed to the editor.
+const editNamesHeader = `# Edit the names after the numbers to rename files, delete a line to move
+# that file to the trash. Keep the numbers as they are. Lines starting with
+# # are ignored.
+`
+
+// nameEdits are the changes made to file names in the editor.
+type nameEdits struct {
+	paneID  int
+	renames []journalItem
+	trash   []file
+}
+
+// editNamesCmd writes the names of files to a temporary file and suspends
+// the UI to let the user edit it. Names containing line breaks can't be
+// edited this way and are left out.
+func editNamesCmd(p pane, files []file) tea.Cmd {
+	tmp, err := os.CreateTemp("", "twin-names-*.txt")
+	if err != nil {
+		return func() tea.Msg { return namesEditedMsg{paneID: p.id, err: err} }
+	}
+	var listed []file
+	w := bufio.NewWriter(tmp)
+	w.WriteString(editNamesHeader)
+	for _, f := range files {
+		if strings.ContainsAny(f.Name, "\n\r") {
+			continue
+		}
+		listed = append(listed, f)
+		fmt.Fprintf(w, "%d\t%s\n", len(listed), f.Name)
+	}
+	err = w.Flush()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return func() tea.Msg { return namesEditedMsg{paneID: p.id, err: err} }
+	}
+
+	path := tmp.Name()
+	cmd, err := editorCommand(path)
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg { return namesEditedMsg{paneID: p.id, err: err} }
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return namesEditedMsg{paneID: p.id, err: fmt.Errorf("editor: %w", err)}
+		}
+		edits, err := readNameEdits(path, p, listed)
+		return namesEditedMsg{paneID: p.id, edits: edits, err: err}
+	})
+}
+
+// readNameEdits compares the edited file at path with the files that were
+// written to it and checks the new names against the rest of the folder.
+func readNameEdits(path string, p pane, files []file) (nameEdits, error) {
+	edits := nameEdits{paneID: p.id}
+	f, err := os.Open(path)
+	if err != nil {
+		return edits, err
+	}
+	defer f.Close()
+
+	names := make(map[int]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		number, name, ok := strings.Cut(text, "\t")
+		n, err := strconv.Atoi(strings.TrimSpace(number))
+		if !ok || err != nil || n < 1 || n > len(files) {
+			return edits, fmt.Errorf("line %d: expected a number from the list, a tab and a name", line)
+		}
+		if _, ok := names[n]; ok {
+			return edits, fmt.Errorf("line %d: number %d is used twice", line, n)
+		}
+		if name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+			return edits, fmt.Errorf("line %d: invalid name %q", line, name)
+		}
+		names[n] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return edits, err
+	}
+
+	leaving := make(map[string]bool) // Names that are given up by the edit
+	taken := make(map[string]bool)   // New names, to catch duplicates
+	for i, f := range files {
+		name, ok := names[i+1]
+		if !ok {
+			edits.trash = append(edits.trash, f)
+			leaving[f.Name] = true
+			continue
+		}
+		if taken[name] {
+			return edits, fmt.Errorf("%s is used for more than one file", name)
+		}
+		taken[name] = true
+		if name != f.Name {
+			edits.renames = append(edits.renames, journalItem{From: f.Path, To: filepath.Join(p.path, name)})
+			leaving[f.Name] = true
+		}
+	}
+	existing := folderNames(p.path)
+	for _, r := range edits.renames {
+		if name := filepath.Base(r.To); existing[name] && !leaving[name] {
+			return edits, fmt.Errorf("%s already exists", name)
+		}
+	}
+	return edits, nil
+}
+
+// applyNameEditsCmd moves the removed files to the trash and then renames the
+// others, so freed names can be taken.
+func applyNameEditsCmd(edits nameEdits) tea.Cmd {
+	return func() tea.Msg {
+		msg := namesAppliedMsg{paneID: edits.paneID}
+		var errs []error
+		for _, f := range edits.trash {
+			entry, err := trashFile(f.Path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to trash %s: %w", f.Name, err))
+				continue
+			}
+			msg.trashed = append(msg.trashed, journalItem{From: f.Path, Trash: entry})
+		}
+		if len(errs) == 0 {
+			var err error
+			if msg.renamed, err = applyRenames(edits.renames); err != nil {
+				errs = append(errs, err)
+			}
+		} else if len(edits.renames) > 0 {
+			errs = append(errs, errors.New("renames skipped"))
+		}
+		msg.err = errors.Join(errs...)
+		return msg
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Programs tried in order when the environment doesn't name an editor or pager.
+var (
+	editorFallbacks = []string{"nano", "vim", "vi"}
+	pagerFallbacks  = []string{"less", "more"}
+)
+
+// findProgram returns the command line from the first of envVars that is set,
+// or else the first of fallbacks that is installed.
+func findProgram(what string, envVars, fallbacks []string) ([]string, error) {
+	for _, v := range envVars {
+		if args := strings.Fields(os.Getenv(v)); len(args) > 0 {
+			return args, nil
+		}
+	}
+	for _, name := range fallbacks {
+		args := strings.Fields(name)
+		if len(args) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(args[0]); err == nil {
+			return args, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s found: set $%s", what, envVars[len(envVars)-1])
+}
+
+// editorCommand returns the command running the user's editor on path.
+func editorCommand(path string) (*exec.Cmd, error) {
+	args, err := findProgram("editor", []string{"VISUAL", "EDITOR"}, editorFallbacks)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(args[0], append(args[1:], path)...), nil
+}
+
+// pagerCommand returns the command running the user's pager on path.
+func pagerCommand(path string) (*exec.Cmd, error) {
+	args, err := findProgram("pager", []string{"PAGER"}, pagerFallbacks)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(args[0], append(args[1:], path)...), nil
+}
+
+// runExternalCmd suspends the UI while cmd runs in the terminal, in the
+// folder of focusPath unless cmd has its own. The pane is reloaded afterwards
+// with the cursor on focusPath, to show any changes.
+func runExternalCmd(paneID int, focusPath string, cmd *exec.Cmd, err error) tea.Cmd {
+	if err != nil {
+		return func() tea.Msg { return externalExitedMsg{paneID: paneID, focusPath: focusPath, err: err} }
+	}
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(focusPath)
+	}
+	name := filepath.Base(cmd.Path)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		return externalExitedMsg{paneID: paneID, focusPath: focusPath, err: err}
+	})
+}
+
+// editFileCmd opens path in the user's editor.
+func editFileCmd(paneID int, path string) tea.Cmd {
+	cmd, err := editorCommand(path)
+	return runExternalCmd(paneID, path, cmd, err)
+}
+
+// pageFileCmd opens path in the user's pager.
+func pageFileCmd(paneID int, path string) tea.Cmd {
+	cmd, err := pagerCommand(path)
+	return runExternalCmd(paneID, path, cmd, err)
+}
+
+// hasDisplay reports whether a graphical session is available to open files in.
+func hasDisplay() bool {
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
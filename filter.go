@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// filterKind is how a filter's query matches file names.
+type filterKind int
+
+const (
+	filterSubstring filterKind = iota // The name contains the query
+	filterGlob                        // The name matches the query as a glob
+	filterRegex                       // The name matches the query as a regular expression
+	filterKindCount
+)
+
+var filterKindNames = [filterKindCount]string{"substring", "glob", "regex"}
+
+func (k filterKind) String() string {
+	return filterKindNames[k]
+}
+
+// listFilter narrows a pane's listing to the files whose names match its
+// query, ignoring case. The ".." entry is always kept.
+type listFilter struct {
+	kind  filterKind
+	query string
+	re    *regexp.Regexp // Compiled query of a regex filter
+	err   error          // Why the query of a regex filter doesn't compile
+}
+
+// set changes the filter's kind and query.
+func (f *listFilter) set(kind filterKind, query string) {
+	f.kind, f.query, f.re, f.err = kind, query, nil, nil
+	if kind == filterRegex && query != "" {
+		f.re, f.err = regexp.Compile("(?i)" + query)
+	}
+}
+
+// active reports whether the filter leaves files out. A regex that doesn't
+// compile leaves the listing as it is.
+func (f listFilter) active() bool {
+	return f.query != "" && f.err == nil
+}
+
+// match reports whether name passes the filter.
+func (f listFilter) match(name string) bool {
+	switch f.kind {
+	case filterGlob:
+		ok, _ := filepath.Match(strings.ToLower(f.query), strings.ToLower(name))
+		return ok
+	case filterRegex:
+		return f.re.MatchString(name)
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(f.query))
+}
+
+// apply returns the files passing the filter.
+func (f listFilter) apply(files []file) []file {
+	if !f.active() {
+		return files
+	}
+	var kept []file
+	for _, file := range files {
+		if file.Name == ".." || f.match(file.Name) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// setFiles replaces the pane's listing with files, narrowed by its filter.
+// Selected files the filter leaves out stay selected, but operations only
+// act on the files shown.
+func (p *pane) setFiles(files []file) {
+	p.unfiltered = files
+	p.files = p.filter.apply(files)
+}
+
+// setFilter changes the pane's filter and applies it to the listing, keeping
+// the cursor on its file when it is still shown.
+func (p *pane) setFilter(kind filterKind, query string) {
+	var focusPath string
+	if p.cursor < len(p.files) {
+		focusPath = p.files[p.cursor].Path
+	}
+	p.filter.set(kind, query)
+	p.files = p.filter.apply(p.unfiltered)
+	p.cursor = 0
+	for i, f := range p.files {
+		if f.Path == focusPath {
+			p.cursor = i
+			break
+		}
+	}
+	p.scrollToCursor()
+}
+
+// clearFilter drops the pane's filter, keeping its kind for the next one.
+func (p *pane) clearFilter() {
+	p.filterInput = nil
+	p.setFilter(p.filter.kind, "")
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// findType restricts a search to one kind of file.
+type findType int
+
+const (
+	findAny findType = iota
+	findFiles
+	findFolders
+	findLinks
+	findTypeCount
+)
+
+var findTypeNames = [findTypeCount]string{"any", "files", "folders", "links"}
+
+func (t findType) String() string {
+	return findTypeNames[t]
+}
+
+// Fields of the find dialog.
+const (
+	findFieldName = iota
+	findFieldSize
+	findFieldModified
+	findFieldCount
+)
+
+// findDialog is the state of the find file dialog.
+type findDialog struct {
+	root   string
+	inputs [findFieldCount]textInput
+	focus  int
+	regex  bool // Match names with a regular expression instead of a glob
+	kind   findType
+	err    error // Why the criteria can't be used
+}
+
+// newFindDialog opens the dialog for searching the tree under root.
+func newFindDialog(root string) *findDialog {
+	d := &findDialog{root: root}
+	for i := range d.inputs {
+		d.inputs[i] = newTextInput("")
+	}
+	return d
+}
+
+// update handles a key in the dialog, bound to action. Up and down move
+// between the fields too.
+func (d *findDialog) update(msg tea.KeyMsg, action string) {
+	switch {
+	case action == "prompt_next_field" || msg.String() == "down":
+		d.focus = (d.focus + 1) % findFieldCount
+	case action == "prompt_prev_field" || msg.String() == "up":
+		d.focus = (d.focus + findFieldCount - 1) % findFieldCount
+	case action == "prompt_regex":
+		d.regex = !d.regex
+	case action == "prompt_option":
+		d.kind = (d.kind + 1) % findTypeCount
+	default:
+		d.inputs[d.focus].update(msg)
+	}
+	_, d.err = d.criteria(time.Now())
+}
+
+// describe sums up the search for the header of the results.
+func (d *findDialog) describe() string {
+	var parts []string
+	if name := d.inputs[findFieldName].Value(); name != "" {
+		parts = append(parts, strconv.Quote(name))
+	}
+	if size := d.inputs[findFieldSize].Value(); size != "" {
+		parts = append(parts, "size "+size)
+	}
+	if modified := d.inputs[findFieldModified].Value(); modified != "" {
+		parts = append(parts, "modified "+modified)
+	}
+	if d.kind != findAny {
+		parts = append(parts, d.kind.String())
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "everything")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// findCriteria is what a search looks for.
+type findCriteria struct {
+	name       func(name string) bool
+	minSize    int64
+	maxSize    int64 // Negative for no limit
+	after      time.Time
+	before     time.Time // Zero for no limit
+	kind       findType
+	skipHidden bool // Don't list hidden files nor look into hidden folders
+}
+
+// criteria reads the dialog's fields. Ages in the modified field count back
+// from now.
+func (d *findDialog) criteria(now time.Time) (findCriteria, error) {
+	c := findCriteria{kind: d.kind, maxSize: -1}
+
+	pattern := strings.TrimSpace(d.inputs[findFieldName].Value())
+	switch {
+	case pattern == "":
+		c.name = func(string) bool { return true }
+	case d.regex:
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return c, fmt.Errorf("name: %w", err)
+		}
+		c.name = re.MatchString
+	default:
+		// A name without wildcards matches names containing it
+		if !strings.ContainsAny(pattern, "*?[") {
+			pattern = "*" + pattern + "*"
+		}
+		pattern = strings.ToLower(pattern)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return c, fmt.Errorf("name: %w", err)
+		}
+		c.name = func(name string) bool {
+			ok, _ := filepath.Match(pattern, strings.ToLower(name))
+			return ok
+		}
+	}
+
+	var err error
+	if c.minSize, c.maxSize, err = parseSizeRange(d.inputs[findFieldSize].Value()); err != nil {
+		return c, fmt.Errorf("size: %w", err)
+	}
+	if c.after, c.before, err = parseTimeRange(d.inputs[findFieldModified].Value(), now); err != nil {
+		return c, fmt.Errorf("modified: %w", err)
+	}
+	return c, nil
+}
+
+// splitRange splits "a..b" into its ends. A value without ".." is returned
+// as the low end, with single set.
+func splitRange(s string) (low, high string, single bool) {
+	low, high, found := strings.Cut(strings.TrimSpace(s), "..")
+	return strings.TrimSpace(low), strings.TrimSpace(high), !found
+}
+
+// parseSizeRange reads "min..max", where either end can be left out. A single
+// size is a minimum.
+func parseSizeRange(s string) (minSize, maxSize int64, err error) {
+	low, high, _ := splitRange(s)
+	maxSize = -1
+	if low != "" {
+		if minSize, err = parseSize(low); err != nil {
+			return 0, 0, err
+		}
+	}
+	if high != "" {
+		if maxSize, err = parseSize(high); err != nil {
+			return 0, 0, err
+		}
+	}
+	return minSize, maxSize, nil
+}
+
+// parseSize reads a size in bytes with an optional K, M, G or T suffix for
+// the binary units formatBytes prints, such as "512", "10K" or "1.5M".
+func parseSize(s string) (int64, error) {
+	unit := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", strings.ToUpper(s[n-1:])[0]); i >= 0 {
+			unit = int64(1) << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number of bytes such as 512, 10K or 1.5M", s)
+	}
+	return int64(v * float64(unit)), nil
+}
+
+// parseTimeRange reads "from..to", where either end is a date (2006-01-02)
+// or an age (30m, 2h, 7d, 4w) and can be left out. A single age means since
+// then, a single date that day.
+func parseTimeRange(s string, now time.Time) (after, before time.Time, err error) {
+	low, high, single := splitRange(s)
+	if low != "" {
+		if after, err = parseTimePoint(low, now); err != nil {
+			return after, before, err
+		}
+		if _, dateErr := time.ParseInLocation(time.DateOnly, low, time.Local); single && dateErr == nil {
+			before = after.AddDate(0, 0, 1)
+		}
+	}
+	if high != "" {
+		if before, err = parseTimePoint(high, now); err != nil {
+			return after, before, err
+		}
+		if _, dateErr := time.ParseInLocation(time.DateOnly, high, time.Local); dateErr == nil {
+			before = before.AddDate(0, 0, 1) // The end date is included
+		}
+	}
+	if !after.IsZero() && !before.IsZero() && after.After(before) {
+		after, before = before, after
+	}
+	return after, before, nil
+}
+
+// parseTimePoint reads a date or an age counted back from now.
+func parseTimePoint(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	units := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(s); n > 1 {
+		if unit, ok := units[s[n-1]]; ok {
+			if v, err := strconv.ParseFloat(s[:n-1], 64); err == nil && v >= 0 {
+				return now.Add(-time.Duration(v * float64(unit))), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date such as 2024-01-31 or an age such as 30m, 2h, 7d or 4w", s)
+}
+
+// match reports whether f is what the search looks for. Sizes only apply to
+// files.
+func (c findCriteria) match(f file) bool {
+	isLink := f.Mode&os.ModeSymlink != 0
+	switch c.kind {
+	case findFiles:
+		if f.IsDir || isLink {
+			return false
+		}
+	case findFolders:
+		if !f.IsDir {
+			return false
+		}
+	case findLinks:
+		if !isLink {
+			return false
+		}
+	}
+	if (c.minSize > 0 || c.maxSize >= 0) && (f.IsDir || f.Size < c.minSize || c.maxSize >= 0 && f.Size > c.maxSize) {
+		return false
+	}
+	if !c.after.IsZero() && f.ModTime.Before(c.after) || !c.before.IsZero() && !f.ModTime.Before(c.before) {
+		return false
+	}
+	return c.name(f.Name)
+}
+
+// findSearch is a search walking a tree in the background, filling a pane
+// with what it finds.
+type findSearch struct {
+	id      int
+	cancel  context.CancelFunc
+	results chan tea.Msg // Closed once the search is over
+}
+
+// findBatchDelay is how long found files wait to be shown along with others.
+const findBatchDelay = 100 * time.Millisecond
+
+// walkFunc looks for files, sending them on found as they turn up. It stops
+// early, returning the context's error, once ctx is cancelled.
+type walkFunc func(ctx context.Context, found chan<- []file) error
+
+// startSearch runs walk in the background on behalf of the pane paneID.
+func startSearch(id, paneID int, walk walkFunc) *findSearch {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &findSearch{id: id, cancel: cancel, results: make(chan tea.Msg)}
+	go s.stream(ctx, paneID, walk)
+	return s
+}
+
+// stream sends the files walk finds to the pane in batches, at most every
+// findBatchDelay, and a findDoneMsg at the end.
+func (s *findSearch) stream(ctx context.Context, paneID int, walk walkFunc) {
+	defer close(s.results)
+	send := func(msg tea.Msg) bool {
+		select {
+		case s.results <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	found := make(chan []file)
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- walk(ctx, found)
+		close(found)
+	}()
+
+	ticker := time.NewTicker(findBatchDelay)
+	defer ticker.Stop()
+	var batch []file
+	for {
+		select {
+		case files, ok := <-found:
+			if !ok {
+				err := <-walkErr
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if len(batch) > 0 && !send(findResultsMsg{paneID: paneID, id: s.id, files: batch}) {
+					return
+				}
+				send(findDoneMsg{paneID: paneID, id: s.id, err: err})
+				return
+			}
+			batch = append(batch, files...)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				if !send(findResultsMsg{paneID: paneID, id: s.id, files: batch}) {
+					return
+				}
+				batch = nil
+			}
+		}
+	}
+}
+
+// emit sends files on found unless ctx is cancelled first.
+func emit(ctx context.Context, found chan<- []file, files ...file) bool {
+	select {
+	case found <- files:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// walkTree calls visit for the entries of the tree under root, skipping
+// hidden ones when skipHidden is set and folders that can't be read.
+func walkTree(ctx context.Context, root string, skipHidden bool, visit func(path string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if skipHidden && isHidden(file{Name: d.Name()}, nil) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return visit(path, d)
+	})
+}
+
+// findWalk looks for the files under root matching c.
+func findWalk(root string, c findCriteria) walkFunc {
+	return func(ctx context.Context, found chan<- []file) error {
+		return walkTree(ctx, root, c.skipHidden, func(path string, d fs.DirEntry) error {
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if f := newFile(path, info); c.match(f) && !emit(ctx, found, f) {
+				return ctx.Err()
+			}
+			return nil
+		})
+	}
+}
+
+// listenCmd waits for the next message of the search.
+func (s *findSearch) listenCmd() tea.Cmd {
+	return func() tea.Msg {
+		if msg, ok := <-s.results; ok {
+			return msg
+		}
+		return nil
+	}
+}
+
+// startFind closes the find dialog and lists what it looks for in the active
+// pane as it is found.
+func (m *model) startFind() tea.Cmd {
+	d := m.findDialog
+	c, err := d.criteria(time.Now())
+	if err != nil {
+		d.err = err
+		return nil
+	}
+	m.findDialog = nil
+	c.skipHidden = !m.activePane().showHidden
+	return m.listSearch(d.root, d.describe(), findWalk(d.root, c))
+}
+
+// listSearch turns the active pane into the results of walk, a search of
+// the tree under root described by info.
+func (m *model) listSearch(root, info string, walk walkFunc) tea.Cmd {
+	p := m.activePane()
+	p.stopFind()
+	p.clearFilter()
+	m.findID++
+	p.finder = startSearch(m.findID, p.id, walk)
+	p.findInfo = info
+	p.listing = listingFind
+	p.path = root
+	p.searchQuery = ""
+	p.cursor = 0
+	p.viewportY = 0
+	p.selected = make(map[string]struct{})
+	p.setFiles([]file{{Name: "..", Path: root, IsDir: true, Mode: os.ModeDir}})
+	return p.finder.listenCmd()
+}
+
+// stopFind cancels the search filling the pane, if it is still running.
+func (p *pane) stopFind() {
+	if p.finder != nil {
+		p.finder.cancel()
+		p.finder = nil
+	}
+}
+
+// leaveFind goes from the search results to the folder of f, with the cursor
+// on it. The ".." entry goes back to the folder searched.
+func (p pane) leaveFind(f file) (pane, tea.Cmd) {
+	p.stopFind()
+	p.clearFilter()
+	p.listing = listingDirectory
+	p.selected = make(map[string]struct{})
+	p.cursor = 0
+	p.viewportY = 0
+	if f.Name == ".." {
+		p.path = f.Path
+		return p, p.loadDirectoryCmd("")
+	}
+	p.path = filepath.Dir(f.Path)
+	return p, p.loadDirectoryCmd(f.Path)
+}
+
+// loadFoundCmd checks the files found are still there, for reloading the
+// results after file operations.
+func (p pane) loadFoundCmd(focusPath string) tea.Cmd {
+	found := p.unfiltered
+	return func() tea.Msg {
+		var files []file
+		for _, f := range found {
+			if f.Name == ".." {
+				files = append(files, f)
+			} else if info, err := os.Lstat(f.Path); err == nil {
+				found := newFile(f.Path, info)
+				found.Match = f.Match
+				files = append(files, found)
+			}
+		}
+		return directoryLoadedMsg{paneID: p.id, files: files, focusPath: focusPath}
+	}
+}
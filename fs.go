@@ -1,27 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
-	"time"
+	"syscall"
 )
 
 // Helper to get file structs from selected paths
 func getFilesFromSelected(p pane) []file {
 	var files []file
+	seen := make(map[string]bool) // Content search results list files once per line found
 	for _, f := range p.files {
-		if _, ok := p.selected[f.Path]; ok {
+		if _, ok := p.selected[f.Path]; ok && !seen[f.Path] {
+			seen[f.Path] = true
 			files = append(files, f)
 		}
 	}
 	return files
 }
 
-// copyFile copies a single file from src to dst.
-func copyFile(src, dst string) error {
+// copyBufferSize is the chunk size used when copying file contents.
+const copyBufferSize = 256 * 1024
+
+// progressFunc receives the file being copied and the number of bytes written
+// to it since the last call.
+type progressFunc func(path string, n int64)
+
+// copier copies files and directory trees, preserving timestamps, ownership
+// (when permitted), extended attributes, symlinks and hardlinks.
+type copier struct {
+	ctx         context.Context
+	progress    progressFunc
+	dereference bool              // Copy what symlinks point to instead of the links themselves
+	links       map[fileID]string // Destination of each multiply-linked file copied so far
+	folders     map[fileID]bool   // Folders on the path being copied, to catch symlink loops
+}
+
+func newCopier(ctx context.Context, progress progressFunc, dereference bool) *copier {
+	return &copier{ctx: ctx, progress: progress, dereference: dereference, links: make(map[fileID]string), folders: make(map[fileID]bool)}
+}
+
+// copy copies src to dst, whatever kind of file src is.
+func (c *copier) copy(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if !c.dereference {
+			return c.copySymlink(src, dst, info)
+		}
+		if info, err = os.Stat(src); err != nil {
+			return err
+		}
+	}
+	if info.IsDir() {
+		return c.copyDir(src, dst, info)
+	}
+	return c.copyFile(src, dst, info)
+}
+
+// copyFile copies a single file from src to dst, reporting written bytes to progress.
+// If the copy fails or ctx is cancelled, the partly written dst is removed.
+func (c *copier) copyFile(src, dst string, info os.FileInfo) error {
+	// Keep files that are hardlinked within the copied tree hardlinked
+	id, multiLinked := hardlinkID(info)
+	if multiLinked {
+		if first, ok := c.links[id]; ok {
+			os.Remove(dst)
+			if err := os.Link(first, dst); err == nil {
+				if c.progress != nil {
+					c.progress(src, info.Size())
+				}
+				return nil
+			}
+		}
+	}
+
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -32,28 +93,75 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
 
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
+	buf := make([]byte, copyBufferSize)
+	for {
+		if err = c.ctx.Err(); err != nil {
+			break
+		}
+		var n int
+		n, err = sourceFile.Read(buf)
+		if n > 0 {
+			if _, werr := destFile.Write(buf[:n]); werr != nil {
+				err = werr
+				break
+			}
+			if c.progress != nil {
+				c.progress(src, int64(n))
+			}
+		}
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
 	}
 
-	sourceInfo, err := os.Stat(src)
+	if cerr := destFile.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
+		os.Remove(dst) // Don't leave a truncated copy behind
 		return err
 	}
-	return os.Chmod(dst, sourceInfo.Mode())
+
+	if multiLinked {
+		c.links[id] = dst
+	}
+	return preserveMetadata(src, dst, info)
 }
 
-// copyDir recursively copies a directory from src to dst.
-func copyDir(src, dst string) error {
-	sourceInfo, err := os.Stat(src)
+// copySymlink recreates the symlink src at dst.
+func (c *copier) copySymlink(src, dst string, info os.FileInfo) error {
+	target, err := os.Readlink(src)
 	if err != nil {
 		return err
 	}
+	if existing, err := os.Lstat(dst); err == nil && !existing.IsDir() {
+		os.Remove(dst)
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+	return preserveMetadata(src, dst, info)
+}
+
+// copyDir recursively copies a directory from src to dst. A followed symlink
+// leading back to a folder being copied is an error, as the copy would never
+// end.
+func (c *copier) copyDir(src, dst string, info os.FileInfo) error {
+	if id, ok := folderID(info); ok {
+		if c.folders[id] {
+			return fmt.Errorf("symlink loop: %s leads back to a folder it is in", src)
+		}
+		c.folders[id] = true
+		defer delete(c.folders, id)
+	}
 
-	err = os.MkdirAll(dst, sourceInfo.Mode())
+	// Stay writable while filling the directory; the real mode is set afterwards
+	err := os.MkdirAll(dst, info.Mode().Perm()|0700)
 	if err != nil {
 		return err
 	}
@@ -64,25 +172,121 @@ func copyDir(src, dst string) error {
 	}
 
 	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		err = c.copy(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	// Set after the contents, since adding entries changes the directory's mtime
+	return preserveMetadata(src, dst, info)
+}
 
-		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
+// preserveMetadata copies ownership, permissions, extended attributes and
+// timestamps from src to dst. Ownership and attributes the user isn't
+// allowed to set are skipped.
+func preserveMetadata(src, dst string, info os.FileInfo) error {
+	isLink := info.Mode()&os.ModeSymlink != 0
+	preserveOwner(dst, info)
+	if !isLink {
+		if err := os.Chmod(dst, info.Mode()); err != nil {
+			return err
+		}
+	}
+	copyXattrs(src, dst)
+	return setTimes(dst, accessTime(info), info.ModTime(), isLink)
+}
+
+// treeSize returns the total size in bytes of the regular files under path.
+func treeSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
 			if err != nil {
 				return err
 			}
-		} else {
-			err = copyFile(srcPath, dstPath)
-			if err != nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// verifyCopy checks that every entry under src exists under dst with the same
+// type and, for regular files, the same size.
+func verifyCopy(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		srcInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dstInfo, err := os.Lstat(filepath.Join(dst, rel))
+		if err != nil {
+			return fmt.Errorf("copy verification failed: %w", err)
+		}
+		if srcInfo.Mode()&os.ModeSymlink != 0 && dstInfo.Mode()&os.ModeSymlink == 0 {
+			// The link was dereferenced, compare against what it points to
+			if srcInfo, err = os.Stat(path); err != nil {
 				return err
 			}
 		}
+		if srcInfo.IsDir() != dstInfo.IsDir() {
+			return fmt.Errorf("copy verification failed: %s has a different type", rel)
+		}
+		if srcInfo.Mode().IsRegular() && srcInfo.Size() != dstInfo.Size() {
+			return fmt.Errorf("copy verification failed: %s is %d bytes, copy is %d", rel, srcInfo.Size(), dstInfo.Size())
+		}
+		return nil
+	})
+}
+
+// copyAndRemove moves src to dst across filesystems: it copies it with c,
+// verifies the copy and only then removes the source.
+func copyAndRemove(c *copier, src, dst string) error {
+	_, statErr := os.Lstat(dst)
+	existed := statErr == nil
+
+	err := c.copy(src, dst)
+	if err == nil {
+		err = verifyCopy(src, dst)
+	}
+	if err != nil {
+		if !existed {
+			os.RemoveAll(dst) // Don't leave a half-moved copy behind
+		}
+		return err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("copied, but could not remove source: %w", err)
 	}
 	return nil
 }
 
-// readDirectory reads the contents of a directory and returns a sorted list of file structs.
+// movePath renames src to dst, falling back to copyAndRemove when they are on
+// different filesystems.
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		err = copyAndRemove(newCopier(context.Background(), nil, false), src, dst)
+	}
+	return err
+}
+
+// readDirectory reads the contents of a directory. The files come in no
+// particular order, see pane.arrange.
 func readDirectory(dirPath string) ([]file, error) {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -97,35 +301,33 @@ func readDirectory(dirPath string) ([]file, error) {
 			continue
 		}
 
-		files = append(files, file{
-			Name:    entry.Name(),
-			Path:    filepath.Join(dirPath, entry.Name()),
-			Size:    info.Size(),
-			Mode:    info.Mode(),
-			ModTime: info.ModTime(),
-			IsDir:   entry.IsDir(),
-		})
+		files = append(files, newFile(filepath.Join(dirPath, entry.Name()), info))
 	}
 
-	// Sort files: directories first, then alphabetically
-	sort.Slice(files, func(i, j int) bool {
-		if files[i].IsDir != files[j].IsDir {
-			return files[i].IsDir // Directories come before files
-		}
-		return files[i].Name < files[j].Name
-	})
+	return files, nil
+}
 
-	// Add ".." entry if not root
-	if filepath.Dir(dirPath) != dirPath {
-		parent := file{
-			Name:    "..",
-			Path:    filepath.Dir(dirPath),
-			IsDir:   true,
-			Mode:    os.ModeDir,
-			ModTime: time.Now(), // Dummy time
-		}
-		files = append([]file{parent}, files...)
+// folderNames returns the names of all the entries of dir, including those
+// that listings leave out because they are hidden or filtered, for checking
+// new names against.
+func folderNames(dir string) map[string]bool {
+	names := make(map[string]bool)
+	entries, _ := os.ReadDir(dir) // What could be read is still worth checking
+	for _, entry := range entries {
+		names[entry.Name()] = true
 	}
+	return names
+}
 
-	return files, nil
+// newFile describes the file at path from its Lstat info.
+func newFile(path string, info os.FileInfo) file {
+	return file{
+		Name:    filepath.Base(path),
+		Path:    path,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+		Owner:   ownerName(info),
+	}
 }
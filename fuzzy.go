@@ -0,0 +1,69 @@
+package main
+
+import "unicode"
+
+// fuzzyMatch reports whether the runes of pattern appear in s in the same
+// order, ignoring case, and scores the match: higher is better. Matches at the
+// start of words and runs of consecutive runes score higher, gaps lower.
+// positions are the indexes of the matched runes in s. An empty pattern
+// matches everything with a score of 0.
+func fuzzyMatch(pattern, s string) (score int, positions []int, ok bool) {
+	pat := []rune(pattern)
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	runes := []rune(s)
+	fold := func(r rune) rune { return unicode.ToLower(r) }
+
+	// Find where the first match ends, then walk back from there to its
+	// latest start, which gives the tightest match ending at that point
+	end, p := -1, 0
+	for i, r := range runes {
+		if fold(r) == fold(pat[p]) {
+			p++
+			if p == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	positions = make([]int, len(pat))
+	p = len(pat) - 1
+	for i := end; p >= 0; i-- {
+		if fold(runes[i]) == fold(pat[p]) {
+			positions[p] = i
+			p--
+		}
+	}
+
+	for k, i := range positions {
+		score++
+		if isWordStart(runes, i) {
+			score += 8
+		}
+		if k > 0 {
+			if gap := i - positions[k-1] - 1; gap == 0 {
+				score += 4
+			} else {
+				score -= min(gap, 5)
+			}
+		}
+	}
+	return score, positions, true
+}
+
+// isWordStart reports whether the rune at i begins a word of s: it follows a
+// separator or is an upper-case letter after a lower-case one.
+func isWordStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, r := runes[i-1], runes[i]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(r)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Fields of the content search dialog.
+const (
+	grepFieldPattern = iota
+	grepFieldInclude
+	grepFieldExclude
+	grepFieldCount
+)
+
+const (
+	grepMaxFileSize   = 16 << 20 // Larger files aren't searched
+	grepMaxSnippet    = 200      // Runes of a matching line listed
+	grepSnippetBefore = 40       // Runes kept before the match when a line is cut
+)
+
+// grepDialog is the state of the content search dialog.
+type grepDialog struct {
+	root       string
+	inputs     [grepFieldCount]textInput
+	focus      int
+	regex      bool // Search for a regular expression instead of plain text
+	ignoreCase bool
+	err        error // Why the criteria can't be used
+}
+
+// newGrepDialog opens the dialog for searching the contents of the files
+// under root.
+func newGrepDialog(root string) *grepDialog {
+	d := &grepDialog{root: root}
+	for i := range d.inputs {
+		d.inputs[i] = newTextInput("")
+	}
+	return d
+}
+
+// update handles a key in the dialog, bound to action. Up and down move
+// between the fields too.
+func (d *grepDialog) update(msg tea.KeyMsg, action string) {
+	switch {
+	case action == "prompt_next_field" || msg.String() == "down":
+		d.focus = (d.focus + 1) % grepFieldCount
+	case action == "prompt_prev_field" || msg.String() == "up":
+		d.focus = (d.focus + grepFieldCount - 1) % grepFieldCount
+	case action == "prompt_regex":
+		d.regex = !d.regex
+	case action == "prompt_option":
+		d.ignoreCase = !d.ignoreCase
+	default:
+		d.inputs[d.focus].update(msg)
+	}
+	if d.inputs[grepFieldPattern].Value() != "" {
+		_, d.err = d.criteria()
+	} else {
+		d.err = nil
+	}
+}
+
+// describe sums up the search for the header of the results.
+func (d *grepDialog) describe() string {
+	pattern := strconv.Quote(d.inputs[grepFieldPattern].Value())
+	if d.regex {
+		pattern = "/" + d.inputs[grepFieldPattern].Value() + "/"
+	}
+	parts := []string{"lines matching " + pattern}
+	if d.ignoreCase {
+		parts = append(parts, "any case")
+	}
+	if include := strings.TrimSpace(d.inputs[grepFieldInclude].Value()); include != "" {
+		parts = append(parts, "in "+include)
+	}
+	if exclude := strings.TrimSpace(d.inputs[grepFieldExclude].Value()); exclude != "" {
+		parts = append(parts, "not in "+exclude)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// grepCriteria is what a content search looks for.
+type grepCriteria struct {
+	re         *regexp.Regexp
+	include    []string // Globs the names of the files searched match, any file if empty
+	exclude    []string // Globs of the names of files and folders left out
+	skipHidden bool     // Don't search hidden files nor look into hidden folders
+}
+
+// criteria reads the dialog's fields.
+func (d *grepDialog) criteria() (grepCriteria, error) {
+	var g grepCriteria
+	pattern := d.inputs[grepFieldPattern].Value()
+	if pattern == "" {
+		return g, fmt.Errorf("nothing to search for")
+	}
+	if !d.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if d.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	var err error
+	if g.re, err = regexp.Compile(pattern); err != nil {
+		return g, fmt.Errorf("pattern: %w", err)
+	}
+	if g.include, err = parseGlobs(d.inputs[grepFieldInclude].Value()); err != nil {
+		return g, fmt.Errorf("include: %w", err)
+	}
+	if g.exclude, err = parseGlobs(d.inputs[grepFieldExclude].Value()); err != nil {
+		return g, fmt.Errorf("exclude: %w", err)
+	}
+	return g, nil
+}
+
+// parseGlobs splits a list of globs separated by spaces or commas.
+func parseGlobs(s string) ([]string, error) {
+	globs := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, glob := range globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", glob, err)
+		}
+	}
+	return globs, nil
+}
+
+// matchesAny reports whether name matches one of globs.
+func matchesAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// grepMatch is a line found by a content search.
+type grepMatch struct {
+	Line int    // Line number, from 1
+	Text string // The line, cut down to a snippet around the match
+	re   *regexp.Regexp
+}
+
+// searchFile returns an entry for each line of f matching the search.
+// Binary files and files over grepMaxFileSize are skipped.
+func (g grepCriteria) searchFile(f file) []file {
+	if f.Size > grepMaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(f.Path)
+	if err != nil || isBinary(content) {
+		return nil
+	}
+	var found []file
+	for i, line := range strings.Split(string(content), "\n") {
+		loc := g.re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		match := f
+		match.Match = &grepMatch{Line: i + 1, Text: snippet(line, loc[0]), re: g.re}
+		found = append(found, match)
+	}
+	return found
+}
+
+// snippet cuts line down to grepMaxSnippet runes, keeping the match at byte
+// offset start in view, and trims its spaces.
+func snippet(line string, start int) string {
+	line = strings.TrimRight(line, "\r")
+	if utf8.RuneCountInString(line) > grepMaxSnippet {
+		runes := []rune(line)
+		from := max(0, utf8.RuneCountInString(line[:start])-grepSnippetBefore)
+		to := min(len(runes), from+grepMaxSnippet)
+		line = string(runes[from:to])
+	}
+	return strings.TrimSpace(line)
+}
+
+// grepWorkers returns the number of files searched at once.
+func grepWorkers() int {
+	return clamp(runtime.NumCPU(), 2, 8)
+}
+
+// grepWalk searches the contents of the files under root for g, reading
+// several files at once.
+func grepWalk(root string, g grepCriteria) walkFunc {
+	return func(ctx context.Context, found chan<- []file) error {
+		files := make(chan file)
+		var wg sync.WaitGroup
+		for range grepWorkers() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for f := range files {
+					if ctx.Err() != nil {
+						continue // Drain what the walk already sent
+					}
+					if matches := g.searchFile(f); len(matches) > 0 {
+						emit(ctx, found, matches...)
+					}
+				}
+			}()
+		}
+
+		err := walkTree(ctx, root, g.skipHidden, func(path string, d fs.DirEntry) error {
+			if matchesAny(g.exclude, d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || len(g.include) > 0 && !matchesAny(g.include, d.Name()) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			select {
+			case files <- newFile(path, info):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(files)
+		wg.Wait()
+		return err
+	}
+}
+
+// startGrep closes the content search dialog and lists the lines found in
+// the active pane as they are found.
+func (m *model) startGrep() tea.Cmd {
+	d := m.grepDialog
+	g, err := d.criteria()
+	if err != nil {
+		d.err = err
+		return nil
+	}
+	m.grepDialog = nil
+	g.skipHidden = !m.activePane().showHidden
+	return m.listSearch(d.root, d.describe(), grepWalk(d.root, g))
+}
+
+// openMatch opens the preview on the file of a line found by a content
+// search, scrolled to the line.
+func (m *model) openMatch(f file) tea.Cmd {
+	activePane := m.activePane()
+	m.isPreviewing = true
+	m.previewFilePath = f.Path
+	m.previewWidth = activePane.width
+	m.previewHeight = activePane.height
+	m.previewScrollY = 0
+	m.previewMatch = f.Match
+	return previewFileCmd(f.Path, f.Match.Line)
+}
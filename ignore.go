@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Hidden entries besides dot files, set from the config.
+var (
+	ignoreGlobs  []string // Names to hide, as filepath.Match patterns
+	useGitignore bool     // Hide what the .gitignore files of the enclosing repository ignore
+)
+
+// ignoreRule is a pattern line of a .gitignore file.
+type ignoreRule struct {
+	base     string // Folder of the file the rule comes from
+	pattern  string
+	negate   bool // The rule starts with "!" and brings back what earlier rules ignored
+	dirOnly  bool // The rule ends with "/" and only matches folders
+	anchored bool // The rule holds a "/" and matches paths relative to base rather than names
+}
+
+// gitignoreRules returns the rules that apply to the entries of dir: those of
+// the repository's info/exclude file, then those of every .gitignore from the
+// top of the repository down to dir. There are none outside a repository.
+func gitignoreRules(dir string) []ignoreRule {
+	root := dir
+	for {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return nil
+		}
+		root = parent
+	}
+
+	rules := readIgnoreFile(filepath.Join(root, ".git", "info", "exclude"), root)
+	rel, _ := filepath.Rel(root, dir)
+	folder := root
+	rules = append(rules, readIgnoreFile(filepath.Join(folder, ".gitignore"), folder)...)
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			folder = filepath.Join(folder, name)
+			rules = append(rules, readIgnoreFile(filepath.Join(folder, ".gitignore"), folder)...)
+		}
+	}
+	return rules
+}
+
+// readIgnoreFile parses the .gitignore file at path, whose rules are relative
+// to base. A missing file has no rules.
+func readIgnoreFile(path, base string) []ignoreRule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`) // Escapes a leading "#" or "!"
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// gitignored reports whether rules ignore f. As in git, the last rule that
+// matches decides.
+func gitignored(rules []ignoreRule, f file) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !f.IsDir {
+			continue
+		}
+		var match bool
+		if r.anchored {
+			rel, err := filepath.Rel(r.base, f.Path)
+			match = err == nil && globMatch(r.pattern, filepath.ToSlash(rel))
+		} else {
+			match, _ = filepath.Match(r.pattern, f.Name)
+		}
+		if match {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// globMatch matches a slash-separated path against pattern, where "**"
+// stands for any number of folders and the other parts are matched with
+// filepath.Match.
+func globMatch(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// isHidden reports whether f is left out of listings while hidden files
+// aren't shown: it is a dot file, matches an ignore glob or is ignored by
+// rules.
+func isHidden(f file, rules []ignoreRule) bool {
+	if strings.HasPrefix(f.Name, ".") {
+		return true
+	}
+	for _, glob := range ignoreGlobs {
+		if ok, _ := filepath.Match(glob, f.Name); ok {
+			return true
+		}
+	}
+	return gitignored(rules, f)
+}
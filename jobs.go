@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// progressInterval is the minimum time between two progress updates sent to the UI.
+const progressInterval = 100 * time.Millisecond
+
+// jobKind is the file operation performed by a job.
+type jobKind int
+
+const (
+	jobCopy jobKind = iota
+	jobMove
+	jobTrash
+	jobDelete // Permanent removal
+)
+
+func (k jobKind) String() string {
+	switch k {
+	case jobMove:
+		return "Move"
+	case jobTrash:
+		return "Trash"
+	case jobDelete:
+		return "Delete"
+	default:
+		return "Copy"
+	}
+}
+
+// verb returns the present participle used while the job runs (e.g. "Copying").
+func (k jobKind) verb() string {
+	switch k {
+	case jobMove:
+		return "Moving"
+	case jobTrash:
+		return "Trashing"
+	case jobDelete:
+		return "Deleting"
+	default:
+		return "Copying"
+	}
+}
+
+// journalKind returns how the job is recorded in the undo journal. Permanent
+// deletion can't be undone and isn't recorded.
+func (k jobKind) journalKind() (journalKind, bool) {
+	switch k {
+	case jobCopy:
+		return journalCopy, true
+	case jobMove:
+		return journalMove, true
+	case jobTrash:
+		return journalTrash, true
+	default:
+		return 0, false
+	}
+}
+
+// jobState is the lifecycle state of a job.
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	jobPaused
+	jobFailed
+	jobCancelled
+	jobDone
+)
+
+func (s jobState) String() string {
+	switch s {
+	case jobRunning:
+		return "running"
+	case jobPaused:
+		return "paused"
+	case jobFailed:
+		return "failed"
+	case jobCancelled:
+		return "cancelled"
+	case jobDone:
+		return "done"
+	default:
+		return "queued"
+	}
+}
+
+// finished reports whether the job has stopped for good (until retried).
+func (s jobState) finished() bool {
+	return s == jobFailed || s == jobCancelled || s == jobDone
+}
+
+// operationProgress is a snapshot of how far a running job has got.
+type operationProgress struct {
+	BytesDone   int64
+	BytesTotal  int64
+	ItemsDone   int
+	ItemsTotal  int
+	CurrentFile string
+	Elapsed     time.Duration
+}
+
+// fraction returns the completed share of the job in the range [0, 1].
+func (p operationProgress) fraction() float64 {
+	if p.BytesTotal > 0 {
+		return float64(p.BytesDone) / float64(p.BytesTotal)
+	}
+	if p.ItemsTotal > 0 {
+		return float64(p.ItemsDone) / float64(p.ItemsTotal)
+	}
+	return 0
+}
+
+// throughput returns the average copy speed in bytes per second.
+func (p operationProgress) throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDone) / p.Elapsed.Seconds()
+}
+
+// eta estimates the time left until the job completes.
+func (p operationProgress) eta() time.Duration {
+	speed := p.throughput()
+	if speed <= 0 || p.BytesTotal <= p.BytesDone {
+		return 0
+	}
+	return time.Duration(float64(p.BytesTotal-p.BytesDone) / speed * float64(time.Second))
+}
+
+// job is a queued copy, move or delete. Its fields are only touched from
+// Update; the goroutine running it communicates through jobQueue.events.
+type job struct {
+	id          int
+	kind        jobKind
+	files       []file         // Files to operate on once conflicts are resolved
+	destPath    string         // Destination directory for copy and move
+	dereference bool           // Copy what symlinks point to instead of the links themselves
+	conflicts   []fileConflict // Conflicts still awaiting a decision
+	state       jobState
+	err         error
+	progress    operationProgress
+	ctl         *jobControl // Set once the job has been started
+}
+
+// newJob creates a job for files. Conflicts are filled in by the caller.
+func newJob(kind jobKind, files []file, destPath string) *job {
+	return &job{kind: kind, files: files, destPath: destPath}
+}
+
+// title describes the job in one line (e.g. "Copy 3 items to /tmp").
+func (j *job) title() string {
+	what := fmt.Sprintf("%d items", len(j.files))
+	if len(j.files) == 1 {
+		what = j.files[0].Name
+	}
+	if j.kind == jobDelete || j.kind == jobTrash {
+		return fmt.Sprintf("%s %s", j.kind, what)
+	}
+	return fmt.Sprintf("%s %s to %s", j.kind, what, j.destPath)
+}
+
+// jobControl lets the UI pause, resume and cancel a running job.
+type jobControl struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	resume chan struct{} // Non-nil while paused; closed on resume
+}
+
+func newJobControl() *jobControl {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobControl{ctx: ctx, cancel: cancel}
+}
+
+func (c *jobControl) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resume == nil {
+		c.resume = make(chan struct{})
+	}
+}
+
+func (c *jobControl) unpause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+	}
+}
+
+// checkpoint blocks while the job is paused and returns an error once it has been cancelled.
+func (c *jobControl) checkpoint() error {
+	c.mu.Lock()
+	resume := c.resume
+	c.mu.Unlock()
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-c.ctx.Done():
+		}
+	}
+	return c.ctx.Err()
+}
+
+// jobQueue runs jobs one at a time in the background.
+type jobQueue struct {
+	jobs   []*job
+	nextID int
+	events chan tea.Msg
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{nextID: 1, events: make(chan tea.Msg, 16)}
+}
+
+// listenCmd waits for the next message emitted by any running job.
+func (q *jobQueue) listenCmd() tea.Cmd {
+	return func() tea.Msg {
+		return <-q.events
+	}
+}
+
+// add appends j to the queue and starts it if nothing else is running.
+func (q *jobQueue) add(j *job) {
+	j.id = q.nextID
+	q.nextID++
+	j.state = jobQueued
+	q.jobs = append(q.jobs, j)
+	q.schedule()
+}
+
+// get returns the job with the given ID, or nil.
+func (q *jobQueue) get(id int) *job {
+	for _, j := range q.jobs {
+		if j.id == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// running returns the job currently doing work, or nil.
+func (q *jobQueue) running() *job {
+	for _, j := range q.jobs {
+		if j.state == jobRunning {
+			return j
+		}
+	}
+	return nil
+}
+
+// count returns the number of jobs in the given state.
+func (q *jobQueue) count(state jobState) int {
+	n := 0
+	for _, j := range q.jobs {
+		if j.state == state {
+			n++
+		}
+	}
+	return n
+}
+
+// schedule starts the next queued job unless one is already running. A job
+// that was paused while running and has been resumed since carries on from
+// where it stopped.
+func (q *jobQueue) schedule() {
+	if q.running() != nil {
+		return
+	}
+	for _, j := range q.jobs {
+		if j.state == jobQueued && j.ctl != nil {
+			j.state = jobRunning
+			j.ctl.unpause()
+			return
+		}
+		if j.state == jobQueued {
+			j.state = jobRunning
+			j.ctl = newJobControl()
+			j.progress = operationProgress{ItemsTotal: len(j.files)}
+			r := &jobRunner{
+				id: j.id, kind: j.kind, files: j.files, destPath: j.destPath, dereference: j.dereference,
+				ctl: j.ctl, events: q.events, started: time.Now(),
+			}
+			go r.run()
+			return
+		}
+	}
+}
+
+// pause stops j at its next checkpoint, or keeps it from starting if it is still queued.
+// A paused job gives up its slot so the rest of the queue keeps moving.
+func (q *jobQueue) pause(j *job) {
+	switch j.state {
+	case jobRunning:
+		j.ctl.pause()
+		j.state = jobPaused
+		q.schedule()
+	case jobQueued:
+		j.state = jobPaused
+	}
+}
+
+// resume puts a paused job back into the queue. One that was paused while
+// running waits for the running job to finish before it carries on.
+func (q *jobQueue) resume(j *job) {
+	if j.state != jobPaused {
+		return
+	}
+	j.state = jobQueued
+	q.schedule()
+}
+
+// cancel stops j. A started job reports back through jobFinishedMsg.
+func (q *jobQueue) cancel(j *job) {
+	if j.state.finished() {
+		return
+	}
+	if j.ctl == nil {
+		j.state = jobCancelled
+		return
+	}
+	j.ctl.cancel()
+}
+
+// retry puts a failed or cancelled job back into the queue.
+func (q *jobQueue) retry(j *job) {
+	if j.state != jobFailed && j.state != jobCancelled {
+		return
+	}
+	j.state = jobQueued
+	j.err = nil
+	j.ctl = nil
+	q.schedule()
+}
+
+// finish records the outcome of a job and starts the next one. If only some
+// items failed, or a cancelled job didn't reach them all, the job is narrowed
+// down to those so a retry skips the rest.
+func (q *jobQueue) finish(id int, err error, failed []file) *job {
+	j := q.get(id)
+	if j == nil {
+		return nil
+	}
+	if len(failed) > 0 {
+		j.files = failed
+	}
+	j.err = err
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.state = jobCancelled
+		j.err = nil
+	case err != nil:
+		j.state = jobFailed
+	default:
+		j.state = jobDone
+	}
+	q.schedule()
+	return j
+}
+
+// clearFinished removes jobs that are done, failed or cancelled.
+func (q *jobQueue) clearFinished() {
+	var kept []*job
+	for _, j := range q.jobs {
+		if !j.state.finished() {
+			kept = append(kept, j)
+		}
+	}
+	q.jobs = kept
+}
+
+// jobRunner performs a job in its own goroutine and streams
+// jobProgressMsg values followed by a final jobFinishedMsg.
+type jobRunner struct {
+	id          int
+	kind        jobKind
+	files       []file
+	destPath    string
+	dereference bool
+	ctl         *jobControl
+	events      chan<- tea.Msg
+	copier      *copier
+	failed      []file              // Items that failed or a cancelled job didn't reach, for a retry
+	done        []journalItem       // Items completed so far, for the undo journal
+	placed      map[string]struct{} // Destinations items of the job went to
+
+	mu       sync.Mutex
+	progress operationProgress
+	started  time.Time
+	lastSent time.Time
+}
+
+func (r *jobRunner) run() {
+	defer r.ctl.cancel()
+
+	// A move keeps symlinks as they are, whatever the copy setting says
+	r.copier = newCopier(r.ctl.ctx, r.addBytes, r.dereference && r.kind == jobCopy)
+
+	r.mu.Lock()
+	r.progress.ItemsTotal = len(r.files)
+	r.mu.Unlock()
+
+	var err error
+	switch r.kind {
+	case jobMove:
+		err = r.runMove()
+	case jobTrash, jobDelete:
+		err = r.runDelete()
+	default:
+		err = r.runCopy()
+	}
+	r.events <- jobFinishedMsg{id: r.id, err: err, failed: r.failed, done: r.done}
+}
+
+// runCopy copies each file into place. Like a move, a failed item doesn't
+// stop the rest, and only the failed ones, along with those a cancelled copy
+// didn't reach, are left for a retry, so it doesn't copy the others again.
+func (r *jobRunner) runCopy() error {
+	var failures []string
+	fail := func(f file, err error) {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.Name, err))
+		r.failed = append(r.failed, f)
+	}
+
+	// Items whose size can't be read can't be copied either
+	var total int64
+	var files []file
+	for _, f := range r.files {
+		size, err := treeSize(f.Path)
+		if err != nil {
+			fail(f, err)
+			continue
+		}
+		total += size
+		files = append(files, f)
+	}
+	r.mu.Lock()
+	r.progress.BytesTotal = total
+	r.mu.Unlock()
+
+	for i, srcFile := range files {
+		if err := r.ctl.checkpoint(); err != nil {
+			r.failed = append(r.failed, files[i:]...) // Left for a retry
+			return err
+		}
+		r.setCurrent(srcFile.Name)
+		destFilePath := filepath.Join(r.destPath, srcFile.Name)
+		if r.taken(destFilePath) {
+			fail(srcFile, fmt.Errorf("another item was already copied to %s", destFilePath))
+			r.itemDone()
+			continue
+		}
+		// Undoing a copy trashes what it made, so one that replaced or merged
+		// into an existing item isn't recorded: it would trash data the copy
+		// didn't create
+		_, err := os.Lstat(destFilePath)
+		existed := err == nil
+		err = r.copier.copy(srcFile.Path, destFilePath)
+		if errors.Is(err, context.Canceled) {
+			r.failed = append(r.failed, files[i:]...)
+			return err
+		}
+		if err != nil {
+			fail(srcFile, err)
+		} else {
+			r.place(destFilePath)
+			if !existed {
+				r.done = append(r.done, journalItem{From: srcFile.Path, To: destFilePath})
+			}
+		}
+		r.itemDone()
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to copy %d of %d items: %s", len(failures), len(r.files), strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// runMove renames each file into place, falling back to copy-then-delete when
+// the destination is on another filesystem. A failed item doesn't stop the
+// rest; all failures are reported together at the end.
+func (r *jobRunner) runMove() error {
+	var failures []string
+	for i, srcFile := range r.files {
+		if err := r.ctl.checkpoint(); err != nil {
+			r.failed = append(r.failed, r.files[i:]...) // Left for a retry
+			return err
+		}
+		r.setCurrent(srcFile.Name)
+		destFilePath := filepath.Join(r.destPath, srcFile.Name)
+		var err error
+		if r.taken(destFilePath) {
+			err = fmt.Errorf("another item was already moved to %s", destFilePath)
+		} else {
+			err = os.Rename(srcFile.Path, destFilePath)
+		}
+		if errors.Is(err, syscall.EXDEV) {
+			if size, serr := treeSize(srcFile.Path); serr == nil {
+				r.mu.Lock()
+				r.progress.BytesTotal += size
+				r.mu.Unlock()
+			}
+			err = copyAndRemove(r.copier, srcFile.Path, destFilePath)
+		}
+		if err == nil {
+			r.place(destFilePath)
+			r.done = append(r.done, journalItem{From: srcFile.Path, To: destFilePath})
+		}
+		if errors.Is(err, context.Canceled) {
+			r.failed = append(r.failed, r.files[i:]...)
+			return err
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", srcFile.Name, err))
+			r.failed = append(r.failed, srcFile)
+		}
+		r.itemDone()
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to move %d of %d items: %s", len(failures), len(r.files), strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// runDelete trashes or removes each file. Like a move, a failed item doesn't
+// stop the rest, and only the failed ones are left for a retry.
+func (r *jobRunner) runDelete() error {
+	var failures []string
+	for i, f := range r.files {
+		if err := r.ctl.checkpoint(); err != nil {
+			r.failed = append(r.failed, r.files[i:]...) // Left for a retry
+			return err
+		}
+		r.setCurrent(f.Name)
+		var err error
+		switch {
+		case r.kind == jobTrash:
+			var entry trashEntry
+			if entry, err = trashFile(f.Path); err == nil {
+				r.done = append(r.done, journalItem{From: f.Path, Trash: entry})
+			}
+		case f.IsDir:
+			err = os.RemoveAll(f.Path)
+		default:
+			err = os.Remove(f.Path)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", f.Name, err))
+			r.failed = append(r.failed, f)
+		}
+		r.itemDone()
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to %s %d of %d items: %s", strings.ToLower(r.kind.String()), len(failures), len(r.files), strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// taken reports whether an earlier item of the job went to path. Items with
+// the same name from different folders must not replace each other, even when
+// overwriting was allowed.
+func (r *jobRunner) taken(path string) bool {
+	_, ok := r.placed[path]
+	return ok
+}
+
+// place records that an item of the job went to path.
+func (r *jobRunner) place(path string) {
+	if r.placed == nil {
+		r.placed = make(map[string]struct{})
+	}
+	r.placed[path] = struct{}{}
+}
+
+func (r *jobRunner) setCurrent(name string) {
+	r.mu.Lock()
+	r.progress.CurrentFile = name
+	r.mu.Unlock()
+	r.report(true)
+}
+
+// addBytes is the progressFunc handed to the copy helpers. It also pauses the
+// copy between chunks while the job is paused.
+func (r *jobRunner) addBytes(path string, n int64) {
+	r.mu.Lock()
+	r.progress.CurrentFile = filepath.Base(path)
+	r.progress.BytesDone += n
+	r.mu.Unlock()
+	r.report(false)
+	r.ctl.checkpoint()
+}
+
+func (r *jobRunner) itemDone() {
+	r.mu.Lock()
+	r.progress.ItemsDone++
+	r.mu.Unlock()
+	r.report(false)
+}
+
+// report sends a progress snapshot to the UI, at most once per progressInterval
+// unless force is set.
+func (r *jobRunner) report(force bool) {
+	r.mu.Lock()
+	now := time.Now()
+	if !force && now.Sub(r.lastSent) < progressInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastSent = now
+	r.progress.Elapsed = now.Sub(r.started)
+	snapshot := r.progress
+	r.mu.Unlock()
+
+	select {
+	case r.events <- jobProgressMsg{id: r.id, Progress: snapshot}:
+	default: // The UI hasn't caught up with earlier updates; drop this one
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// journalKind is the kind of mutating operation recorded in the journal.
+type journalKind int
+
+const (
+	journalMkdir journalKind = iota
+	journalCopy
+	journalMove
+	journalRename
+	journalTrash
+)
+
+func (k journalKind) String() string {
+	switch k {
+	case journalCopy:
+		return "copy"
+	case journalMove:
+		return "move"
+	case journalRename:
+		return "rename"
+	case journalTrash:
+		return "delete"
+	default:
+		return "mkdir"
+	}
+}
+
+// journalItem is one path affected by an operation.
+type journalItem struct {
+	From  string     // Where the item was before the operation (unused for mkdir)
+	To    string     // Where the operation put it
+	Trash trashEntry // Where a deleted item went in the trash
+}
+
+// name returns the file name of the item.
+func (i journalItem) name() string {
+	if i.To == "" {
+		return filepath.Base(i.From)
+	}
+	return filepath.Base(i.To)
+}
+
+// journalEntry is one operation that can be undone and redone.
+type journalEntry struct {
+	Kind  journalKind
+	Items []journalItem
+}
+
+// journal records the operations twin performed so they can be undone and redone.
+type journal struct {
+	undo []journalEntry
+	redo []journalEntry
+}
+
+// record adds a newly performed operation. Any redo history is dropped.
+func (j *journal) record(e journalEntry) {
+	if len(e.Items) == 0 {
+		return
+	}
+	j.undo = append(j.undo, e)
+	j.redo = nil
+}
+
+// popUndo removes and returns the most recent operation.
+func (j *journal) popUndo() (journalEntry, bool) {
+	if len(j.undo) == 0 {
+		return journalEntry{}, false
+	}
+	e := j.undo[len(j.undo)-1]
+	j.undo = j.undo[:len(j.undo)-1]
+	return e, true
+}
+
+// popRedo removes and returns the most recently undone operation.
+func (j *journal) popRedo() (journalEntry, bool) {
+	if len(j.redo) == 0 {
+		return journalEntry{}, false
+	}
+	e := j.redo[len(j.redo)-1]
+	j.redo = j.redo[:len(j.redo)-1]
+	return e, true
+}
+
+// applied files the result of undoing or redoing an entry: the items that
+// went through move to the opposite stack, the ones that failed stay put so
+// they can be tried again.
+func (j *journal) applied(msg journalAppliedMsg) {
+	done, failed := &j.redo, &j.undo
+	if !msg.undo {
+		done, failed = &j.undo, &j.redo
+	}
+	if len(msg.done.Items) > 0 {
+		*done = append(*done, msg.done)
+	}
+	if len(msg.failed.Items) > 0 {
+		*failed = append(*failed, msg.failed)
+	}
+}
+
+// undoCmd reverses e.
+func undoCmd(e journalEntry) tea.Cmd {
+	return applyJournalCmd(e, true)
+}
+
+// redoCmd performs e again after it was undone.
+func redoCmd(e journalEntry) tea.Cmd {
+	return applyJournalCmd(e, false)
+}
+
+func applyJournalCmd(e journalEntry, undo bool) tea.Cmd {
+	return func() tea.Msg {
+		done := journalEntry{Kind: e.Kind}
+		failed := journalEntry{Kind: e.Kind}
+		var errors []string
+		for i := range e.Items {
+			// Undo goes backwards, so renames chained through a temporary name unwind
+			if undo {
+				i = len(e.Items) - 1 - i
+			}
+			item := e.Items[i]
+			var err error
+			if undo {
+				item, err = undoItem(e.Kind, item)
+			} else {
+				item, err = redoItem(e.Kind, item)
+			}
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", item.name(), err))
+				failed.Items = append(failed.Items, item)
+			} else {
+				done.Items = append(done.Items, item)
+			}
+		}
+		if undo {
+			// Keep the entries in the order the operation was performed
+			slices.Reverse(done.Items)
+			slices.Reverse(failed.Items)
+		}
+		msg := journalAppliedMsg{undo: undo, done: done, failed: failed}
+		if len(errors) > 0 {
+			verb := "redo"
+			if undo {
+				verb = "undo"
+			}
+			msg.err = fmt.Errorf("failed to %s %s: %s", verb, e.Kind, strings.Join(errors, ", "))
+		}
+		return msg
+	}
+}
+
+// undoItem reverses a single item and returns it, updated if needed for a later redo.
+func undoItem(kind journalKind, item journalItem) (journalItem, error) {
+	switch kind {
+	case journalMkdir:
+		return item, os.Remove(item.To) // Only succeeds while the folder is still empty
+	case journalCopy:
+		// The copy goes to the trash rather than away for good
+		_, err := trashFile(item.To)
+		return item, err
+	case journalMove, journalRename:
+		if _, err := os.Lstat(item.From); err == nil {
+			return item, fmt.Errorf("%s already exists", item.From)
+		}
+		return item, movePath(item.To, item.From)
+	case journalTrash:
+		return item, restoreTrashed(item.Trash)
+	}
+	return item, fmt.Errorf("unknown operation %v", kind)
+}
+
+// redoItem performs a single item again and returns it, updated if needed for a later undo.
+func redoItem(kind journalKind, item journalItem) (journalItem, error) {
+	switch kind {
+	case journalMkdir:
+		return item, os.Mkdir(item.To, 0755)
+	case journalCopy:
+		if _, err := os.Lstat(item.To); err == nil {
+			return item, fmt.Errorf("%s already exists", item.To)
+		}
+		return item, newCopier(context.Background(), nil, false).copy(item.From, item.To)
+	case journalMove, journalRename:
+		if _, err := os.Lstat(item.To); err == nil {
+			return item, fmt.Errorf("%s already exists", item.To)
+		}
+		return item, movePath(item.From, item.To)
+	case journalTrash:
+		entry, err := trashFile(item.From)
+		if err == nil {
+			item.Trash = entry
+		}
+		return item, err
+	}
+	return item, fmt.Errorf("unknown operation %v", kind)
+}
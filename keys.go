@@ -1,68 +1,262 @@
 package main
 
-// Shortcut represents a keyboard shortcut with a description.
-type Shortcut struct {
-	Key        string // The actual key to press (e.g., "alt+c", "q")
-	DisplayKey string // The key to show in the UI (e.g., "c")
-	FKey       string // Function key alias (e.g., "f5")
-	Modifier   string // "ctrl", "alt", "shift", or empty
-	Action     string // Description for the UI
-	Cmd        string // Internal command identifier
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyMode is the part of the UI a key binding applies to.
+type keyMode int
+
+const (
+	modeGlobal  keyMode = iota // Everywhere, unless the current mode binds the key itself
+	modePane                   // Browsing the panes
+	modePreview                // The preview overlay
+	modePrompt                 // Prompts, inline rename, the multi-rename dialog and questions
+	modeJobs                   // The job list
+)
+
+func (k keyMode) String() string {
+	switch k {
+	case modePane:
+		return "pane"
+	case modePreview:
+		return "preview"
+	case modePrompt:
+		return "prompt"
+	case modeJobs:
+		return "jobs"
+	default:
+		return "global"
+	}
 }
 
-// KeyMap holds all application shortcuts.
+// Action is something keys can be bound to.
+type Action struct {
+	Cmd   string   // Identifier used in the config file, such as "copy"
+	Label string   // Name for the hints bar
+	Help  string   // What it does, for the command palette
+	Mode  keyMode  // Where the bindings apply
+	Keys  []string // Bindings, each a key or a chord of keys separated by spaces (e.g., "alt+c", "g g")
+}
+
+// KeyMap is the registry of actions and the keys bound to them.
 type KeyMap struct {
-	Quit            Shortcut
-	ForceQuit       Shortcut
-	SwitchPane      Shortcut
-	Preview         Shortcut
-	Copy            Shortcut
-	Move            Shortcut
-	NewFolder       Shortcut
-	Delete          Shortcut
-	CopyPath        Shortcut
-	ToggleSelection Shortcut
+	actions []*Action
 }
 
 // DefaultKeyMap returns the default key mapping.
 func DefaultKeyMap() KeyMap {
-	return KeyMap{
-		Quit:            Shortcut{Key: "alt+q", DisplayKey: "q", FKey: "f10", Modifier: "alt", Action: "Quit", Cmd: "quit"},
-		ForceQuit:       Shortcut{Key: "ctrl+c", DisplayKey: "c", Modifier: "ctrl", Action: "Force Quit", Cmd: "force_quit"},
-		SwitchPane:      Shortcut{Key: "tab", DisplayKey: "tab", Modifier: "", Action: "Switch Pane", Cmd: "switch_pane"},
-		Preview:         Shortcut{Key: "alt+v", DisplayKey: "v", FKey: "f3", Modifier: "alt", Action: "View", Cmd: "preview"},
-		Copy:            Shortcut{Key: "alt+c", DisplayKey: "c", FKey: "f5", Modifier: "alt", Action: "Copy", Cmd: "copy"},
-		Move:            Shortcut{Key: "alt+m", DisplayKey: "m", FKey: "f6", Modifier: "alt", Action: "Move", Cmd: "move"},
-		NewFolder:       Shortcut{Key: "alt+n", DisplayKey: "n", FKey: "f7", Modifier: "alt", Action: "MkDir", Cmd: "mkdir"},
-		Delete:          Shortcut{Key: "alt+d", DisplayKey: "d", FKey: "f8", Modifier: "alt", Action: "Delete", Cmd: "delete"},
-		CopyPath:        Shortcut{Key: "alt+p", DisplayKey: "p", FKey: "f9", Modifier: "alt", Action: "Copy Path", Cmd: "copy_path"},
-		ToggleSelection: Shortcut{Key: "alt+i", DisplayKey: "i", Modifier: "alt", Action: "Select", Cmd: "select"},
+	var k KeyMap
+	add := func(mode keyMode, cmd, label, help string, keys ...string) {
+		k.actions = append(k.actions, &Action{Cmd: cmd, Label: label, Help: help, Mode: mode, Keys: keys})
+	}
+
+	add(modeGlobal, "force_quit", "Force Quit", "Quit right away, from anywhere", "ctrl+c")
+
+	add(modePane, "quit", "Quit", "Quit twin", "alt+q", "f10")
+	add(modePane, "switch_pane", "Switch Pane", "Move the focus to the other pane", "tab")
+	add(modePane, "preview", "View", "Show the file under the cursor in the preview", "alt+v", "f3")
+	add(modePane, "pager", "Pager", "View the file under the cursor in the pager", "alt+V", "f15")
+	add(modePane, "edit", "Edit", "Edit the file under the cursor in the editor", "alt+e", "f4")
+	add(modePane, "copy", "Copy", "Copy the selected files to the other pane", "alt+c", "f5")
+	add(modePane, "move", "Move", "Move the selected files to the other pane", "alt+m", "f6")
+	add(modePane, "mkdir", "MkDir", "Create a folder in the active pane", "alt+n", "f7")
+	add(modePane, "goto", "Go To", "Type a path to open in the active pane", "alt+g")
+	add(modePane, "rename", "Rename", "Rename the file under the cursor", "alt+R", "f18")
+	add(modePane, "batch_rename", "Multi-Rename", "Rename the selected files with a pattern", "alt+M")
+	add(modePane, "edit_names", "Edit Names", "Rename or trash files by editing their names in the editor", "alt+E")
+	add(modePane, "delete", "Delete", "Move the selected files to the trash", "alt+d", "f8")
+	add(modePane, "delete_permanently", "Delete Forever", "Delete the selected files for good", "alt+D", "f20")
+	add(modePane, "trash", "Trash", "Show or leave the trash in the active pane", "alt+t")
+	add(modePane, "restore", "Restore", "Put the selected trashed files back", "alt+r")
+	add(modePane, "copy_path", "Copy Path", "Copy the paths of the selected files to the clipboard", "alt+p", "f9")
+	add(modePane, "select", "Select", "Select or unselect the file under the cursor", "alt+i", "insert")
+	add(modePane, "cancel", "Cancel", "Cancel the search filling the active pane, or else the running job", "alt+x")
+	add(modePane, "jobs", "Jobs", "Show the job list", "alt+j")
+	add(modePane, "follow_links", "Follow Links", "Toggle copying what symlinks point to", "alt+l")
+	add(modePane, "layout_next", "Layout", "List the active pane's files in the next mode: brief, full, custom", "alt+w")
+	add(modePane, "cursor_left", "Left", "Move the cursor to the previous column of the brief mode", "left")
+	add(modePane, "cursor_right", "Right", "Move the cursor to the next column of the brief mode", "right")
+	add(modePane, "sort_next", "Sort", "Sort the active pane by the next key: name, natural, extension, size, mtime, unsorted", "alt+s")
+	add(modePane, "sort_reverse", "Reverse", "Sort the active pane in the opposite direction", "alt+S")
+	add(modePane, "sort_ignore_case", "Sort Any Case", "Toggle sorting the active pane without regard to case")
+	add(modePane, "sort_dirs_first", "Dirs First", "Toggle keeping folders above files in the active pane")
+	add(modePane, "find", "Find", "Search the tree under the active pane for files by name, size, date and type", "alt+f7")
+	add(modePane, "grep", "Search Text", "Search the contents of the files under the active pane", "alt+G", "alt+f19")
+	add(modePane, "filter", "Filter", "Narrow the active pane to the files matching a filter", "alt+f")
+	add(modePane, "select_all", "Select All", "Select every file shown in the active pane, or unselect them if they all are", "alt+a")
+	add(modePane, "toggle_hidden", "Hidden", "Show or hide dot files and ignored files in the active pane", "alt+.")
+	add(modePane, "theme", "Theme", "Switch to the next theme", "alt+T")
+	add(modePane, "undo", "Undo", "Undo the last file operation", "alt+z")
+	add(modePane, "redo", "Redo", "Redo the last undone file operation", "alt+Z")
+	add(modePane, "command_line", "Command", "Type a shell command to run in the active folder", "alt+o")
+	add(modePane, "palette", "Commands", "List every command to run one", "ctrl+p", "f2")
+	add(modePane, "cursor_up", "Up", "Move the cursor up", "up")
+	add(modePane, "cursor_down", "Down", "Move the cursor down", "down")
+	add(modePane, "page_up", "Page Up", "Move the cursor up a page", "pgup")
+	add(modePane, "page_down", "Page Down", "Move the cursor down a page", "pgdown")
+	add(modePane, "top", "Top", "Move the cursor to the first file", "home")
+	add(modePane, "bottom", "Bottom", "Move the cursor to the last file", "end")
+	add(modePane, "open", "Open", "Open the folder or file under the cursor", "enter")
+	add(modePane, "search_next", "Next Match", "Move the cursor to the next file matching the type-ahead search", "ctrl+down")
+	add(modePane, "search_prev", "Previous Match", "Move the cursor to the previous file matching the type-ahead search", "ctrl+up")
+	add(modePane, "clear_search", "Clear Search", "Clear the type-ahead search and the filter", "esc")
+
+	add(modePreview, "preview_close", "Close", "Close the preview", "esc", "q")
+	add(modePreview, "preview_up", "Up", "Scroll up a line", "up", "k")
+	add(modePreview, "preview_down", "Down", "Scroll down a line", "down", "j")
+	add(modePreview, "preview_page_up", "Page Up", "Scroll up a page", "pgup")
+	add(modePreview, "preview_page_down", "Page Down", "Scroll down a page", "pgdown")
+	add(modePreview, "preview_top", "Top", "Scroll to the start", "home", "g")
+	add(modePreview, "preview_bottom", "Bottom", "Scroll to the end", "end", "G")
+
+	add(modePrompt, "prompt_confirm", "Confirm", "Accept the answer", "enter")
+	add(modePrompt, "prompt_cancel", "Cancel", "Close without answering", "esc")
+	add(modePrompt, "prompt_terminal", "In Terminal", "Run the command line with the terminal instead of showing its output", "alt+enter")
+	add(modePrompt, "prompt_option", "Option", "Change the option of what is being typed: the filter matches as a substring, a glob or a regex, find looks for any type of file, files, folders or links, the text search ignores case or not and Multi-Rename cycles the case of the new names", "ctrl+t")
+	add(modePrompt, "prompt_next_field", "Next Field", "Move to the next field of a dialog", "tab")
+	add(modePrompt, "prompt_prev_field", "Previous Field", "Move to the previous field of a dialog", "shift+tab")
+	add(modePrompt, "prompt_regex", "Regex", "Switch between a regex and a glob or plain text: the name in the find dialog, the text searched for and the search of Multi-Rename", "ctrl+r")
+
+	add(modeJobs, "jobs_close", "Close", "Close the job list", "esc", "q", "alt+j")
+	add(modeJobs, "jobs_up", "Up", "Select the previous job", "up", "k")
+	add(modeJobs, "jobs_down", "Down", "Select the next job", "down", "j")
+	add(modeJobs, "jobs_pause", "Pause", "Pause or resume the selected job", "p", "space")
+	add(modeJobs, "jobs_retry", "Retry", "Run a failed or cancelled job again", "r")
+	add(modeJobs, "jobs_cancel", "Cancel", "Cancel the selected job", "x", "delete", "alt+x")
+	add(modeJobs, "jobs_clear", "Clear Finished", "Remove finished jobs from the list", "c")
+	return k
+}
+
+// find returns the action for the command cmd.
+func (k KeyMap) find(cmd string) (*Action, bool) {
+	for _, a := range k.actions {
+		if a.Cmd == cmd {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// inMode returns the actions of mode, followed by the global ones that apply
+// to it as well.
+func (k KeyMap) inMode(mode keyMode) []*Action {
+	var actions []*Action
+	for _, m := range []keyMode{mode, modeGlobal} {
+		for _, a := range k.actions {
+			if a.Mode == m {
+				actions = append(actions, a)
+			}
+		}
+		if mode == modeGlobal {
+			break
+		}
+	}
+	return actions
+}
+
+// keyName returns the name of a key press as used in bindings.
+func keyName(msg tea.KeyMsg) string {
+	if msg.String() == " " {
+		return "space" // A space would split the binding into a chord
+	}
+	return msg.String()
+}
+
+// lookup returns the command bound to the keys pressed in mode. prefix
+// reports whether the keys are the start of a longer chord instead.
+func (k KeyMap) lookup(mode keyMode, keys []string) (cmd string, prefix bool) {
+	for _, a := range k.inMode(mode) {
+		for _, binding := range a.Keys {
+			chord := strings.Fields(binding)
+			switch {
+			case slices.Equal(chord, keys):
+				return a.Cmd, false
+			case len(chord) > len(keys) && slices.Equal(chord[:len(keys)], keys):
+				prefix = true
+			}
+		}
 	}
+	return "", prefix
 }
 
-// GetShortcuts returns a slice of all shortcuts for iteration.
-func (k KeyMap) GetShortcuts() []Shortcut {
-	return []Shortcut{
-		k.Quit,
-		k.ForceQuit,
-		k.SwitchPane,
-		k.Preview,
-		k.Copy,
-		k.Move,
-		k.NewFolder,
-		k.Delete,
-		k.CopyPath,
-		k.ToggleSelection,
+// keyFor returns the first key bound to cmd, for showing in the UI.
+func (k KeyMap) keyFor(cmd string) string {
+	if a, ok := k.find(cmd); ok && len(a.Keys) > 0 {
+		return a.Keys[0]
 	}
+	return ""
 }
 
-// GetAliasMap returns a map of aliases to their primary keys
-func (k KeyMap) GetAliasMap() map[string]string {
-	aliases := make(map[string]string)
-	for _, s := range k.GetShortcuts() {
-		if s.FKey != "" {
-			aliases[s.FKey] = s.Key
+// conflicts reports bindings that can't all work: the same keys bound to two
+// actions of a mode, or a key that is also the start of another action's
+// chord. Global bindings are checked against every mode.
+func (k KeyMap) conflicts() []error {
+	type binding struct {
+		action *Action
+		chord  []string
+	}
+	var errs []error
+	seen := make(map[string]bool)
+	for _, mode := range []keyMode{modeGlobal, modePane, modePreview, modePrompt, modeJobs} {
+		var bindings []binding
+		for _, a := range k.inMode(mode) {
+			for _, b := range a.Keys {
+				if chord := strings.Fields(b); len(chord) > 0 {
+					bindings = append(bindings, binding{a, chord})
+				}
+			}
+		}
+		for i, b := range bindings {
+			for _, other := range bindings[i+1:] {
+				if b.action.Cmd == other.action.Cmd {
+					continue
+				}
+				short, long := b, other
+				if len(short.chord) > len(long.chord) {
+					short, long = long, short
+				}
+				if !slices.Equal(long.chord[:len(short.chord)], short.chord) {
+					continue
+				}
+				var err error
+				if len(short.chord) == len(long.chord) {
+					err = fmt.Errorf("keys.%s, keys.%s: %q is bound to both in %s mode",
+						b.action.Cmd, other.action.Cmd, strings.Join(b.chord, " "), mode)
+				} else {
+					err = fmt.Errorf("keys.%s: %q starts the chord %q of %s in %s mode",
+						short.action.Cmd, strings.Join(short.chord, " "), strings.Join(long.chord, " "), long.action.Cmd, mode)
+				}
+				// A global conflict would be found again in every mode
+				if !seen[err.Error()] {
+					seen[err.Error()] = true
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// paneHints are the commands the hints bar shows for the panes, in order, as
+// far as the width allows. The palette lists all the others.
+var paneHints = []string{"quit", "preview", "edit", "copy", "move", "mkdir", "delete", "copy_path", "select", "filter", "jobs"}
+
+// hintKey returns the key of a to show in the hints bar. With a modifier,
+// only a single key using it is shown, without the modifier, which the
+// chip next to the hints names.
+func (a Action) hintKey(modifier string) (string, bool) {
+	for _, b := range a.Keys {
+		if modifier == "" {
+			return b, true
+		}
+		if rest, ok := strings.CutPrefix(b, modifier+"+"); ok && rest != "" && !strings.Contains(rest, " ") {
+			return rest, true
 		}
 	}
-	return aliases
+	return "", false
 }
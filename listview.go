@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// listMode is how a pane lays out its files.
+type listMode int
+
+const (
+	listBrief  listMode = iota // Names only, in columns side by side
+	listFull                   // One file per line with its size, date, permissions and owner
+	listCustom                 // One file per line with the configured columns
+	listModeCount
+)
+
+var listModeNames = [listModeCount]string{"brief", "full", "custom"}
+
+func (l listMode) String() string {
+	return listModeNames[l]
+}
+
+// parseListMode returns the list mode called name.
+func parseListMode(name string) (listMode, error) {
+	for l, n := range listModeNames {
+		if name == n {
+			return listMode(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown list mode %q, expected one of %s", name, strings.Join(listModeNames[:], ", "))
+}
+
+// column is a piece of information shown for each file in the full and
+// custom modes.
+type column int
+
+const (
+	colName column = iota
+	colSize
+	colMTime
+	colPerms
+	colOwner
+	columnCount
+)
+
+var columnNames = [columnCount]string{"name", "size", "mtime", "perms", "owner"}
+
+// parseColumn returns the column called name.
+func parseColumn(name string) (column, error) {
+	for c, n := range columnNames {
+		if name == n {
+			return column(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(columnNames[:], ", "))
+}
+
+// fullColumns are the columns of the full mode.
+var fullColumns = []column{colName, colSize, colMTime, colPerms, colOwner}
+
+const (
+	minNameWidth  = 12 // Narrowest the name column gets before other columns are dropped
+	minBriefWidth = 20 // Narrowest a brief column gets when their number is picked to fit
+)
+
+// width returns the cells the column takes. The name column takes the rest.
+func (c column) width() int {
+	switch c {
+	case colSize:
+		return 9
+	case colMTime:
+		return 16
+	case colPerms:
+		return 10
+	case colOwner:
+		return 8
+	}
+	return 0
+}
+
+func (c column) title() string {
+	return [columnCount]string{"Name", "Size", "Modified", "Perms", "Owner"}[c]
+}
+
+// value returns what the column shows for f.
+func (c column) value(f file) string {
+	switch c {
+	case colSize:
+		if f.IsDir {
+			return "<DIR>"
+		}
+		return formatBytes(f.Size)
+	case colMTime:
+		if f.Name == ".." {
+			return ""
+		}
+		return f.ModTime.Format("2006-01-02 15:04")
+	case colPerms:
+		return f.Mode.String()
+	case colOwner:
+		return f.Owner
+	}
+	return f.Name
+}
+
+// listLayout is the list mode of a pane with its settings.
+type listLayout struct {
+	mode         listMode
+	briefColumns int      // Columns of the brief mode, 0 to fit as many as the width allows
+	columns      []column // Columns of the custom mode
+}
+
+// detailColumns returns the columns shown in the full and custom modes. The
+// rightmost ones are dropped while the name would be narrower than
+// minNameWidth.
+func (p pane) detailColumns() []column {
+	columns := fullColumns
+	if p.layout.mode == listCustom {
+		columns = p.layout.columns
+	}
+	for len(columns) > 1 {
+		used := 1 // Leading space
+		for _, c := range columns {
+			if c != colName {
+				used += c.width() + 1
+			}
+		}
+		if p.width-used >= minNameWidth {
+			break
+		}
+		last := len(columns) - 1
+		if columns[last] == colName {
+			last--
+		}
+		columns = append(columns[:last:last], columns[last+1:]...)
+	}
+	return columns
+}
+
+// briefColumnCount returns the number of columns the files are laid out in.
+func (p pane) briefColumnCount() int {
+	if p.listing != listingDirectory || p.layout.mode != listBrief {
+		return 1
+	}
+	n := p.layout.briefColumns
+	if n == 0 {
+		n = p.width / minBriefWidth
+	}
+	return clamp(n, 1, max(1, p.width/8))
+}
+
+// hasTitles reports whether the list starts with a line of column titles.
+func (p pane) hasTitles() bool {
+	return p.listing == listingDirectory && p.layout.mode != listBrief
+}
+
+// rows returns the number of files that fit in a column.
+func (p pane) rows() int {
+	rows := p.height - 2
+	if p.hasTitles() {
+		rows--
+	}
+	return max(1, rows)
+}
+
+// pageSize returns the number of files visible at once.
+func (p pane) pageSize() int {
+	return p.rows() * p.briefColumnCount()
+}
+
+// scrollToCursor moves the viewport so the cursor is visible. With several
+// columns it moves by whole columns, so files keep their column.
+func (p *pane) scrollToCursor() {
+	rows, n := p.rows(), p.briefColumnCount()
+	if n == 1 {
+		if p.cursor < p.viewportY {
+			p.viewportY = p.cursor
+		}
+		if p.cursor >= p.viewportY+rows {
+			p.viewportY = p.cursor - rows + 1
+		}
+	} else {
+		p.viewportY -= p.viewportY % rows
+		if p.cursor < p.viewportY {
+			p.viewportY = p.cursor / rows * rows
+		}
+		if p.cursor >= p.viewportY+rows*n {
+			p.viewportY = (p.cursor/rows - n + 1) * rows
+		}
+	}
+	p.viewportY = max(0, p.viewportY)
+}
+
+// padRight pads s with spaces to width cells.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-lipgloss.Width(s)))
+}
+
+// listView renders the visible part of the pane's listing, width cells wide.
+func (p pane) listView(width int) string {
+	var s strings.Builder
+	rows, n := p.rows(), p.briefColumnCount()
+	if n > 1 {
+		cellWidth := (width - (n - 1)) / n
+		for r := 0; r < rows; r++ {
+			var cells []string
+			for c := 0; c < n; c++ {
+				i := p.viewportY + c*rows + r
+				if i >= len(p.files) {
+					break
+				}
+				cells = append(cells, p.fileView(i, cellWidth))
+			}
+			if len(cells) == 0 {
+				break
+			}
+			s.WriteString(strings.Join(cells, " ") + "\n")
+		}
+		return s.String()
+	}
+
+	if p.hasTitles() {
+		var titles []string
+		for _, c := range p.detailColumns() {
+			titles = append(titles, c.title())
+		}
+		plain := lipgloss.NewStyle()
+		s.WriteString(trashInfoStyle.Render(p.detailLine(titles, width, nil, plain, plain, plain)) + "\n")
+	}
+	for i := p.viewportY; i < len(p.files) && i < p.viewportY+rows; i++ {
+		s.WriteString(p.fileView(i, width) + "\n")
+	}
+	return s.String()
+}
+
+// detailLine lays out the values of the detail columns in width cells, the
+// name taking what the others leave. The name is rendered with nameStyle,
+// its runes at positions with match, and the rest with style.
+func (p pane) detailLine(values []string, width int, positions []int, style, nameStyle, match lipgloss.Style) string {
+	columns := p.detailColumns()
+	nameWidth := width - 1
+	for _, c := range columns {
+		if c != colName {
+			nameWidth -= c.width() + 1
+		}
+	}
+	var b strings.Builder
+	b.WriteString(style.Render(" "))
+	for i, c := range columns {
+		if i > 0 {
+			b.WriteString(style.Render(" "))
+		}
+		switch c {
+		case colName:
+			b.WriteString(nameView(values[i], max(1, nameWidth), positions, nameStyle, match))
+		case colSize:
+			v := truncateMiddle(values[i], c.width())
+			b.WriteString(style.Render(strings.Repeat(" ", c.width()-lipgloss.Width(v)) + v))
+		default:
+			b.WriteString(style.Render(padRight(truncateMiddle(values[i], c.width()), c.width())))
+		}
+	}
+	return b.String()
+}
+
+// nameView fits name into width cells, shortened in the middle, with the
+// runes at positions rendered with match and the rest with style.
+func nameView(name string, width int, positions []int, style, match lipgloss.Style) string {
+	short := truncateMiddle(name, width)
+	if head, tail, cut := middleCut(name, width); cut {
+		// Follow the runes kept on both sides of the ellipsis
+		runes := utf8.RuneCountInString(name)
+		var kept []int
+		for _, i := range positions {
+			switch {
+			case i < head:
+				kept = append(kept, i)
+			case i >= runes-tail:
+				kept = append(kept, head+1+i-(runes-tail))
+			}
+		}
+		positions = kept
+	}
+	return highlightRunes(padRight(short, width), positions, style, match)
+}
+
+// fileView renders the file at index i width cells wide, styled for the
+// cursor, the selection or its kind, with the runes matching the type-ahead
+// search highlighted.
+func (p pane) fileView(i, width int) string {
+	f := p.files[i]
+	if p.renameInput != nil && f.Path == p.renamePath {
+		return renameRowStyle.Width(width).MaxWidth(width).Render(" " + p.renameInput.View())
+	}
+
+	style, nameStyle, infoStyle, match := lipgloss.NewStyle(), lipgloss.NewStyle(), trashInfoStyle, matchStyle
+	if f.IsDir {
+		nameStyle = dirStyle
+	}
+	_, isSelected := p.selected[f.Path]
+	switch {
+	case i == p.cursor:
+		style = cursorStyle
+	case isSelected:
+		style = selectionStyle
+	}
+	if i == p.cursor || isSelected {
+		// The row takes a single color, with matches underlined instead
+		nameStyle, infoStyle, match = style, style, style.Bold(true).Underline(true)
+	}
+	name := f.Name
+	_, positions, _ := p.searchMatch(f.Name)
+
+	// Trashed files show where they come from, found files their folder and
+	// lines found in files their path, number and text
+	var info string
+	switch e, inTrash := p.trash[f.Path]; {
+	case inTrash:
+		info = fmt.Sprintf("  %s, %s", filepath.Dir(e.OriginalPath), e.DeletionDate.Format("2006-01-02 15:04"))
+	case f.Match != nil:
+		rel, _ := filepath.Rel(p.path, f.Path)
+		name = fmt.Sprintf("%s:%d", rel, f.Match.Line)
+		offset := utf8.RuneCountInString(rel) - utf8.RuneCountInString(f.Name)
+		for k := range positions {
+			positions[k] += offset
+		}
+		info = "  " + f.Match.Text
+	case p.listing == listingFind && f.Name != "..":
+		rel, _ := filepath.Rel(p.path, filepath.Dir(f.Path))
+		info = "  " + rel + string(filepath.Separator)
+	}
+
+	switch {
+	case info != "":
+		nameWidth := lipgloss.Width(truncateMiddle(name, max(minNameWidth, width-1-lipgloss.Width(info))))
+		info = truncateMiddle(info, max(0, width-1-nameWidth))
+		return style.Render(" ") + nameView(name, nameWidth, positions, nameStyle, match) +
+			infoStyle.Render(padRight(info, width-1-nameWidth))
+	case p.hasTitles():
+		columns := p.detailColumns()
+		values := make([]string, len(columns))
+		for k, c := range columns {
+			values[k] = c.value(f)
+		}
+		return p.detailLine(values, width, positions, style, nameStyle, match)
+	}
+	return style.Render(" ") + nameView(f.Name, max(1, width-1), positions, nameStyle, match)
+}
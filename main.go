@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -8,11 +9,34 @@ import (
 )
 
 func main() {
+	printConfig := flag.Bool("print-default-config", false, "print the effective configuration as TOML and exit")
+	flag.Parse()
+
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "twin: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "twin: %v\n", err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		if err := cfg.write(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "twin: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	cfg.apply()
+	term := detectTerminal()
+
 	// Enable Kitty Keyboard Protocol
 	fmt.Print("\x1b[>15u")
 	defer fmt.Print("\x1b[<u")
 
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen(), tea.WithInput(os.Stdin))
+	p := tea.NewProgram(initialModel(cfg, term), tea.WithAltScreen(), tea.WithInput(os.Stdin))
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
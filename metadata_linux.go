@@ -0,0 +1,169 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileID identifies a file on disk, regardless of the path it was reached through.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkID returns the identity of info's file and whether it has more than one link.
+func hardlinkID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, st.Nlink > 1
+}
+
+// folderID returns the identity of info's folder, and whether it is known.
+func folderID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, true
+}
+
+// accessTime returns the last access time recorded in info.
+func accessTime(info os.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(st.Atim.Sec, st.Atim.Nsec)
+}
+
+// ownerNames caches user names by uid, as looking them up reads /etc/passwd.
+var ownerNames sync.Map
+
+// ownerName returns the name of the user owning info's file, or its uid if
+// the user is unknown.
+func ownerName(info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	if name, ok := ownerNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	ownerNames.Store(uid, name)
+	return name
+}
+
+// preserveOwner gives path the uid and gid recorded in info, if the user is allowed to.
+func preserveOwner(path string, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	os.Lchown(path, int(st.Uid), int(st.Gid)) // Fails with EPERM unless we own the target uid
+}
+
+// copyXattrs copies the extended attributes of src to dst. Attributes that
+// can't be read or set (e.g. trusted.* without privileges) are skipped.
+func copyXattrs(src, dst string) {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil || size <= 0 {
+		return
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+		n, err := unix.Lgetxattr(src, name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n, err = unix.Lgetxattr(src, name, value); err != nil {
+			continue
+		}
+		unix.Lsetxattr(dst, name, value[:n], 0)
+	}
+}
+
+// setTimes sets the access and modification times of path, without following
+// it if it is a symlink.
+func setTimes(path string, atime, mtime time.Time, isLink bool) error {
+	ts := []unix.Timespec{unix.NsecToTimespec(atime.UnixNano()), unix.NsecToTimespec(mtime.UnixNano())}
+	flags := 0
+	if isLink {
+		flags = unix.AT_SYMLINK_NOFOLLOW
+	}
+	err := unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, flags)
+	if isLink && errors.Is(err, unix.EOPNOTSUPP) {
+		return nil // Some filesystems can't timestamp symlinks
+	}
+	return err
+}
+
+// deviceOf returns the ID of the device holding path.
+func deviceOf(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// mountPoints returns the mount points listed in /proc/self/mounts.
+func mountPoints() []string {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var points []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		points = append(points, unescapeMountPath(fields[1]))
+	}
+	return points
+}
+
+// unescapeMountPath decodes the octal escapes (e.g. "\040" for a space) used in /proc/self/mounts.
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// fileID identifies a file on disk. Hardlinks aren't detected on this platform.
+type fileID struct{}
+
+// hardlinkID always reports a single link on this platform.
+func hardlinkID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// folderID doesn't know folders apart on this platform, so symlink loops
+// aren't detected.
+func folderID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// accessTime falls back to the modification time on this platform.
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// ownerName isn't supported on this platform.
+func ownerName(info os.FileInfo) string {
+	return ""
+}
+
+// preserveOwner is a no-op on this platform.
+func preserveOwner(path string, info os.FileInfo) {}
+
+// copyXattrs is a no-op on this platform.
+func copyXattrs(src, dst string) {}
+
+// setTimes sets the access and modification times of path. Symlinks are left alone.
+func setTimes(path string, atime, mtime time.Time, isLink bool) error {
+	if isLink {
+		return nil
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+// deviceOf reports every path as being on the same device on this platform,
+// so everything goes to the home trash.
+func deviceOf(path string) (uint64, error) {
+	_, err := os.Stat(path)
+	return 0, err
+}
+
+// mountPoints isn't supported on this platform.
+func mountPoints() []string {
+	return nil
+}
@@ -17,6 +17,8 @@ type file struct {
 	Mode    fs.FileMode
 	ModTime time.Time
 	IsDir   bool
+	Owner   string     // Name of the owning user, when read from a folder
+	Match   *grepMatch // Line found by a content search, for its results
 }
 
 // fileConflict represents a file that already exists at the destination.
@@ -25,11 +27,21 @@ type fileConflict struct {
 	Destination string
 }
 
+// paneListing is what a pane is listing.
+type paneListing int
+
+const (
+	listingDirectory paneListing = iota
+	listingTrash
+	listingFind // Files found by a search under path
+)
+
 // pane represents one of the two file listing panels.
 type pane struct {
 	id          int
 	path        string
 	files       []file
+	unfiltered  []file              // Listing before the filter narrowed it down
 	selected    map[string]struct{} // Paths of selected files
 	cursor      int
 	active      bool
@@ -38,6 +50,18 @@ type pane struct {
 	width       int // Width of the pane's display area
 	searchQuery string
 	err         error // Error encountered during directory loading
+	listing     paneListing
+	trash       map[string]trashEntry // Trashed items by their path in the trash, when listing the trash
+	renameInput *textInput            // Inline editor on the row of the file being renamed
+	renamePath  string                // Path of the file being renamed, which reloads may move in the listing
+	filter      listFilter
+	filterInput *textInput  // Editor of the filter while it is typed
+	finder      *findSearch // Search adding to the listing, while it runs
+	findInfo    string      // What the search listed looks for
+	sort        sortOrder
+	showHidden  bool
+	hidden      int // Files of the folder left out of the listing
+	layout      listLayout
 }
 
 // model is the main application model.
@@ -46,24 +70,45 @@ type model struct {
 	rightPane             pane
 	quitting              bool
 	err                   error
-	isCreatingFolder      bool
-	folderNameInput       string
+	prompt                *prompt // Question being asked in the status bar, if any
+	promptErr             error   // Why the last answer to the prompt was refused
+	histories             map[promptKind]*inputHistory
+	renameErr             error          // Why the last rename attempt was refused
+	batchRename           *batchRename   // Multi-rename dialog, if open
+	findDialog            *findDialog    // Find file dialog, if open
+	grepDialog            *grepDialog    // Content search dialog, if open
+	findID                int            // ID of the last search started
+	nameEdits             *nameEdits     // Changes made in the editor, waiting for confirmation
+	palette               *palette       // Command palette, if open
+	pendingAction         *pendingAction // Custom action waiting for confirmation
+	customActions         map[string]CustomAction
 	isDeleting            bool
 	filesToDelete         []file
+	deletePermanently     bool
 	isConfirmingOverwrite bool
-	overwriteConflicts    []fileConflict
-	overwriteAll          bool
-	skipAll               bool
-	isMoving              bool // To know if the operation is a move or copy
+	conflictJobs          []*job // Jobs waiting for overwrite decisions, the first one is being asked about
+	jobs                  *jobQueue
+	showJobs              bool
+	jobCursor             int
+	jobScrollY            int  // First job shown in the job list
+	followSymlinks        bool // Copy what symlinks point to instead of recreating the links
+	journal               *journal
 	isPreviewing          bool
 	previewContent        string
 	previewFilePath       string
 	previewWidth          int
 	previewHeight         int
 	previewScrollY        int
+	previewMatch          *grepMatch // Line of a content search the preview shows
+	previewMatchLine      int        // Index of that line in previewContent
+	confirm               ConfirmConfig
+	theme                 int    // Index of the theme in use in themes
+	colors                Colors // Configured colors replacing the theme's
+	terminal              terminal
 	keyMap                KeyMap
+	chord                 []string // Keys of a chord typed so far
+	chordMode             keyMode  // Mode the chord was started in
 	modifierState         ModifierState
-	aliasMap              map[string]string
 }
 
 // ModifierState tracks the state of modifier keys.
@@ -73,33 +118,68 @@ type ModifierState struct {
 	Shift bool
 }
 
+// activePane returns the pane that has the focus.
+func (m *model) activePane() *pane {
+	if m.rightPane.active {
+		return &m.rightPane
+	}
+	return &m.leftPane
+}
+
 // initialModel creates a new model with default state.
-func initialModel() model {
+func initialModel(cfg Config, term terminal) model {
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
 	}
+	leftPath, rightPath := cwd, cwd
+	if cfg.Paths.Left != "" {
+		leftPath = cfg.Paths.Left
+	}
+	if cfg.Paths.Right != "" {
+		rightPath = cfg.Paths.Right
+	}
+
+	customActions := make(map[string]CustomAction)
+	for _, a := range cfg.Actions {
+		customActions[a.Name] = a
+	}
+
+	themeIndex, _ := pickTheme(cfg.Theme, term)
+	applyTheme(themes[themeIndex], cfg.Colors, term)
 
-	km := DefaultKeyMap()
 	return model{
 		leftPane: pane{
-			id:       0,
-			path:     cwd,
-			active:   true,
-			selected: make(map[string]struct{}),
+			id:         0,
+			path:       leftPath,
+			active:     true,
+			selected:   make(map[string]struct{}),
+			sort:       cfg.sortOrder(),
+			showHidden: cfg.ShowHidden,
+			layout:     cfg.listLayout(),
 		},
 		rightPane: pane{
-			id:       1,
-			path:     cwd,
-			active:   false,
-			selected: make(map[string]struct{}),
+			id:         1,
+			path:       rightPath,
+			active:     false,
+			selected:   make(map[string]struct{}),
+			sort:       cfg.sortOrder(),
+			showHidden: cfg.ShowHidden,
+			layout:     cfg.listLayout(),
 		},
-		keyMap:   km,
-		aliasMap: km.GetAliasMap(),
+		confirm:       cfg.Confirm,
+		theme:         themeIndex,
+		colors:        cfg.Colors,
+		terminal:      term,
+		jobs:          newJobQueue(),
+		journal:       &journal{},
+		histories:     make(map[promptKind]*inputHistory),
+		keyMap:        cfg.keyMap(),
+		customActions: customActions,
 	}
 }
 
 // Init initializes the application.
 func (m model) Init() tea.Cmd {
-	return tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	return tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""), m.jobs.listenCmd())
 }
@@ -4,34 +4,121 @@ package main
 type directoryLoadedMsg struct {
 	paneID    int
 	files     []file
+	hidden    int // Files left out of the listing
 	err       error
 	focusPath string
+	trash     map[string]trashEntry // Set when the trash was listed
+}
+
+// findResultsMsg carries files found by the search id of a pane.
+type findResultsMsg struct {
+	paneID int
+	id     int
+	files  []file
+}
+
+// findDoneMsg is sent when the search id of a pane has walked its whole tree.
+type findDoneMsg struct {
+	paneID int
+	id     int
+	err    error
 }
 
 type fileOpenedMsg struct {
 	err error
 }
 
+// externalExitedMsg is sent when an editor or pager run on a file has exited.
+type externalExitedMsg struct {
+	paneID    int
+	focusPath string
+	err       error
+}
+
+// commandOutputMsg carries the output of a command run with its output captured.
+type commandOutputMsg struct {
+	paneID int
+	output string
+	err    error
+}
+
+// actionDoneMsg is sent when a custom action has finished.
+type actionDoneMsg struct {
+	paneID    int
+	focusPath string // File to keep the cursor on when reloading
+	output    string // What the action printed
+	show      bool   // Show the output in the preview
+	refresh   bool   // Reload both panes
+	err       error
+}
+
 type folderCreatedMsg struct {
 	err        error
 	folderPath string
 }
 
-type fileDeletedMsg struct {
-	err error
+type fileRenamedMsg struct {
+	paneID int
+	from   string
+	to     string
+	err    error
+}
+
+// batchRenamedMsg is sent when a batch rename has been applied. done holds the
+// renames that went through, in the order they were made.
+type batchRenamedMsg struct {
+	paneID int
+	done   []journalItem
+	err    error
+}
+
+// namesEditedMsg is sent when the editor opened to edit file names has exited.
+type namesEditedMsg struct {
+	paneID int
+	edits  nameEdits
+	err    error
+}
+
+// namesAppliedMsg is sent when the changes made in the editor have been applied.
+type namesAppliedMsg struct {
+	paneID  int
+	trashed []journalItem
+	renamed []journalItem
+	err     error
 }
 
-type fileOperationMsg struct { // For copy/move
+type trashUpdatedMsg struct { // After restoring or purging trashed items
 	err error
 }
 
-type fileConflictMsg struct {
-	Conflicts []fileConflict
+type jobPreparedMsg struct { // A job ready to be queued, possibly with conflicts to resolve first
+	job *job
+	err error // Why some of the files were left out
+}
+
+type jobProgressMsg struct {
+	id       int
+	Progress operationProgress
+}
+
+type jobFinishedMsg struct {
+	id     int
+	err    error
+	failed []file        // Items that failed when the job carried on past errors
+	done   []journalItem // Items completed before the job ended
+}
+
+type journalAppliedMsg struct { // After undoing or redoing a journal entry
+	undo   bool
+	done   journalEntry // Items that were undone or redone
+	failed journalEntry // Items that couldn't be
+	err    error
 }
 
 type previewReadyMsg struct {
-	Content string
-	Err     error
+	Content     string
+	Err         error
+	headerLines int // Lines shown above the file's content
 }
 
 type clipboardCopiedMsg struct {
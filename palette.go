@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// paletteMatch is a command listed in the palette.
+type paletteMatch struct {
+	action    *Action
+	score     int
+	positions []int // Runes of the label matching the query, for highlighting
+}
+
+// palette is the command palette, listing every command the panes can run.
+type palette struct {
+	input   textInput
+	actions []*Action
+	matches []paletteMatch // Commands matching the query, best first
+	cursor  int
+	scrollY int
+}
+
+// newPalette lists the commands of km that can be run from the panes.
+func newPalette(km KeyMap) *palette {
+	p := &palette{input: newTextInput("")}
+	for _, a := range km.inMode(modePane) {
+		if a.Cmd != "palette" {
+			p.actions = append(p.actions, a)
+		}
+	}
+	p.filter()
+	return p
+}
+
+// filter lists the commands whose label or command name matches the query.
+func (p *palette) filter() {
+	query := p.input.Value()
+	p.matches = p.matches[:0]
+	for _, a := range p.actions {
+		score, positions, ok := fuzzyMatch(query, a.Label)
+		if cmdScore, _, cmdOk := fuzzyMatch(query, a.Cmd); cmdOk && (!ok || cmdScore > score) {
+			score, positions, ok = cmdScore, nil, true
+		}
+		if ok {
+			p.matches = append(p.matches, paletteMatch{action: a, score: score, positions: positions})
+		}
+	}
+	slices.SortStableFunc(p.matches, func(a, b paletteMatch) int {
+		return b.score - a.score
+	})
+	p.cursor, p.scrollY = 0, 0
+}
+
+// selected returns the command under the cursor.
+func (p *palette) selected() (string, bool) {
+	if p.cursor >= len(p.matches) {
+		return "", false
+	}
+	return p.matches[p.cursor].action.Cmd, true
+}
+
+// update handles a key press while the palette is open. visible is the
+// number of commands that fit on screen.
+func (p *palette) update(msg tea.KeyMsg, visible int) {
+	switch msg.String() {
+	case "up", "ctrl+p":
+		p.cursor--
+	case "down", "ctrl+n":
+		p.cursor++
+	case "pgup":
+		p.cursor -= visible
+	case "pgdown":
+		p.cursor += visible
+	default:
+		if p.input.update(msg) {
+			p.filter()
+		}
+		return
+	}
+	p.cursor = clamp(p.cursor, 0, max(0, len(p.matches)-1))
+	if p.cursor < p.scrollY {
+		p.scrollY = p.cursor
+	}
+	if p.cursor >= p.scrollY+visible {
+		p.scrollY = p.cursor - visible + 1
+	}
+}
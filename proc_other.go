@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// detach does nothing on this platform; the command just runs in the background.
+func detach(cmd *exec.Cmd) {}
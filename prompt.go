@@ -0,0 +1,54 @@
+package main
+
+// promptKind identifies what a status bar prompt asks for.
+type promptKind int
+
+const (
+	promptMkdir promptKind = iota
+	promptGoTo
+	promptCommand
+	promptRename // Inline rename, for its history
+	promptFilter // Pane filter, for its history
+)
+
+// prompt is a one-line question asked in the status bar.
+type prompt struct {
+	kind  promptKind
+	input textInput
+}
+
+// label returns the text shown in front of the input.
+func (p prompt) label() string {
+	switch p.kind {
+	case promptGoTo:
+		return "Go to: "
+	case promptCommand:
+		return "$ "
+	default:
+		return "Create folder: "
+	}
+}
+
+// openPrompt starts asking for kind in the status bar. Paths are completed
+// relative to dir, on the command line word by word, and every prompt keeps
+// its own history.
+func (m *model) openPrompt(kind promptKind, dir string) {
+	input := newTextInput("").withHistory(m.history(kind))
+	if kind == promptCommand {
+		input = input.withWordCompletion(dir)
+	} else {
+		input = input.withCompletion(dir)
+	}
+	m.prompt = &prompt{kind: kind, input: input}
+	m.promptErr = nil
+}
+
+// history returns the history of the inputs of kind, kept for the session.
+func (m *model) history(kind promptKind) *inputHistory {
+	h, ok := m.histories[kind]
+	if !ok {
+		h = &inputHistory{}
+		m.histories[kind] = h
+	}
+	return h
+}
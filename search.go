@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// searchMode is how the type-ahead search matches file names.
+type searchMode int
+
+const (
+	searchFuzzy  searchMode = iota // The query's letters appear in the name in order
+	searchPrefix                   // The name starts with the query
+	searchModeCount
+)
+
+var searchModeNames = [searchModeCount]string{"fuzzy", "prefix"}
+
+func (s searchMode) String() string {
+	return searchModeNames[s]
+}
+
+// parseSearchMode returns the search mode called name.
+func parseSearchMode(name string) (searchMode, error) {
+	for s, n := range searchModeNames {
+		if name == n {
+			return searchMode(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown search mode %q, expected one of %s", name, strings.Join(searchModeNames[:], ", "))
+}
+
+// typeAheadSearch is the search mode of the panes, set from the config.
+var typeAheadSearch = searchFuzzy
+
+// searchMatch reports whether the file called name matches the pane's
+// search query, with the score of the match and the positions of the
+// matching runes. The ".." entry never matches.
+func (p pane) searchMatch(name string) (score int, positions []int, ok bool) {
+	if p.searchQuery == "" || name == ".." {
+		return 0, nil, false
+	}
+	if typeAheadSearch == searchFuzzy {
+		return fuzzyMatch(p.searchQuery, name)
+	}
+	if !strings.HasPrefix(strings.ToLower(name), strings.ToLower(p.searchQuery)) {
+		return 0, nil, false
+	}
+	positions = make([]int, utf8.RuneCountInString(p.searchQuery))
+	for i := range positions {
+		positions[i] = i
+	}
+	return 0, positions, true
+}
+
+// search moves the cursor to the file matching the query best, the first
+// one of the listing among equals.
+func (p *pane) search() {
+	best := -1
+	bestScore := 0
+	for i, f := range p.files {
+		if score, _, ok := p.searchMatch(f.Name); ok && (best < 0 || score > bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		p.cursor = best
+	}
+}
+
+// nextMatch moves the cursor to the next file matching the query in the
+// listing, or the previous one when delta is negative, wrapping around.
+func (p *pane) nextMatch(delta int) {
+	n := len(p.files)
+	for k := 1; k < n; k++ {
+		i := ((p.cursor+k*delta)%n + n) % n
+		if _, _, ok := p.searchMatch(p.files[i].Name); ok {
+			p.cursor = i
+			return
+		}
+	}
+}
+
+// searchMatchCount returns the number of files matching the query.
+func (p pane) searchMatchCount() int {
+	count := 0
+	for _, f := range p.files {
+		if _, _, ok := p.searchMatch(f.Name); ok {
+			count++
+		}
+	}
+	return count
+}
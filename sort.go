@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// sortKey is what a pane sorts its files by.
+type sortKey int
+
+const (
+	sortByName      sortKey = iota
+	sortByNatural           // Names with numbers in numeric order, so file2 comes before file10
+	sortByExtension         // Extension, then name
+	sortBySize              // Size, then name; folders by name
+	sortByTime              // Modification time, then name
+	sortUnsorted            // The order the folder lists them in
+	sortKeyCount
+)
+
+var sortKeyNames = [sortKeyCount]string{"name", "natural", "extension", "size", "mtime", "unsorted"}
+
+func (k sortKey) String() string {
+	return sortKeyNames[k]
+}
+
+// parseSortKey returns the sort key called name.
+func parseSortKey(name string) (sortKey, error) {
+	for k, n := range sortKeyNames {
+		if strings.EqualFold(name, n) {
+			return sortKey(k), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sort order %q, expected one of %s", name, strings.Join(sortKeyNames[:], ", "))
+}
+
+// sortOrder is how a pane orders its files.
+type sortOrder struct {
+	by         sortKey
+	reverse    bool
+	dirsFirst  bool // Keep folders above files whatever the order
+	ignoreCase bool // Compare names and extensions without regard to case
+}
+
+// String describes the order for the pane header, such as "size ↓".
+func (o sortOrder) String() string {
+	s := o.by.String()
+	if o.by != sortUnsorted {
+		if o.reverse {
+			s += " ↓"
+		} else {
+			s += " ↑"
+		}
+	}
+	if o.ignoreCase {
+		s += ", any case"
+	}
+	if !o.dirsFirst {
+		s += ", dirs mixed"
+	}
+	return s
+}
+
+// less reports whether a sorts before b.
+func (o sortOrder) less(a, b file) bool {
+	if o.dirsFirst && a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+	if o.by == sortUnsorted {
+		return false
+	}
+	c := o.compare(a, b)
+	if o.reverse {
+		return c > 0
+	}
+	return c < 0
+}
+
+// compare orders a and b by the sort key, falling back to their names.
+func (o sortOrder) compare(a, b file) int {
+	c := 0
+	switch o.by {
+	case sortByNatural:
+		c = naturalCompare(o.fold(a.Name), o.fold(b.Name))
+	case sortByExtension:
+		_, extA := splitExt(a)
+		_, extB := splitExt(b)
+		c = strings.Compare(o.fold(extA), o.fold(extB))
+	case sortBySize:
+		if !a.IsDir || !b.IsDir {
+			c = cmp.Compare(a.Size, b.Size)
+		}
+	case sortByTime:
+		c = a.ModTime.Compare(b.ModTime)
+	}
+	if c == 0 {
+		c = strings.Compare(o.fold(a.Name), o.fold(b.Name))
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+	return c
+}
+
+// fold returns s in lower case when case is ignored.
+func (o sortOrder) fold(s string) string {
+	if o.ignoreCase {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// naturalCompare compares a and b with runs of digits compared as numbers,
+// so "file2" comes before "file10". Leading zeros only break ties.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ra, _ := utf8.DecodeRuneInString(a)
+		rb, _ := utf8.DecodeRuneInString(b)
+		if isDigit(ra) && isDigit(rb) {
+			numA, restA := digitRun(a)
+			numB, restB := digitRun(b)
+			trimmedA, trimmedB := strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+			if c := cmp.Compare(len(trimmedA), len(trimmedB)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(len(numA), len(numB)); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ra != rb {
+			return cmp.Compare(ra, rb)
+		}
+		a, b = a[utf8.RuneLen(ra):], b[utf8.RuneLen(rb):]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// isDigit reports whether r is an ASCII digit.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// digitRun splits s after its leading digits.
+func digitRun(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// arrange turns the entries read from the pane's folder into its listing:
+// hidden files are left out unless shown, the rest is sorted and the ".."
+// entry goes on top. rules are the .gitignore rules of the folder. It also
+// returns the number of files left out.
+func (p pane) arrange(files []file, rules []ignoreRule) ([]file, int) {
+	var listed []file
+	hidden := 0
+	for _, f := range files {
+		if !p.showHidden && isHidden(f, rules) {
+			hidden++
+			continue
+		}
+		listed = append(listed, f)
+	}
+	sort.SliceStable(listed, func(i, j int) bool {
+		return p.sort.less(listed[i], listed[j])
+	})
+
+	// Add ".." entry if not root
+	if filepath.Dir(p.path) != p.path {
+		parent := file{
+			Name:    "..",
+			Path:    filepath.Dir(p.path),
+			IsDir:   true,
+			Mode:    os.ModeDir,
+			ModTime: time.Now(), // Dummy time
+		}
+		listed = append([]file{parent}, listed...)
+	}
+	return listed, hidden
+}
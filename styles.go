@@ -2,45 +2,193 @@ package main
 
 import "github.com/charmbracelet/lipgloss"
 
+// Colors are the colors the styles are built from. Each is an ANSI color
+// number ("63") or a hex color ("#5f5fff"), named as in the config file.
+// Empty means no color.
+type Colors struct {
+	Accent      string `toml:"accent,omitempty"`       // Active pane border, cursor, hint keys and chips
+	AccentText  string `toml:"accent_text,omitempty"`  // Text on accent-colored chips
+	CursorText  string `toml:"cursor_text,omitempty"`  // Text on the cursor row
+	Text        string `toml:"text,omitempty"`         // Bright text, such as in prompts
+	Muted       string `toml:"muted,omitempty"`        // Status bar and hint descriptions
+	Dim         string `toml:"dim,omitempty"`          // Secondary information, such as trash locations
+	Border      string `toml:"border,omitempty"`       // Inactive pane border
+	Bar         string `toml:"bar,omitempty"`          // Background of the status bar and prompts
+	HintBg      string `toml:"hint_bg,omitempty"`      // Background of hint descriptions
+	Directory   string `toml:"directory,omitempty"`    // Folder names
+	SelectionBg string `toml:"selection_bg,omitempty"` // Background of selected files
+	SelectionFg string `toml:"selection_fg,omitempty"` // Text of selected files
+	Confirm     string `toml:"confirm,omitempty"`      // Background of confirmation prompts
+	Warning     string `toml:"warning,omitempty"`      // Background of the overwrite prompt
+	Error       string `toml:"error,omitempty"`        // Error text
+	ErrorBar    string `toml:"error_bar,omitempty"`    // Background of errors in the status bar
+	Preview     string `toml:"preview,omitempty"`      // Preview border
+}
+
+// defaultColors are the colors of the dark theme.
+var defaultColors = Colors{
+	Accent:      "63",
+	AccentText:  "0",
+	CursorText:  "255",
+	Text:        "255",
+	Muted:       "250",
+	Dim:         "240",
+	Border:      "240",
+	Bar:         "235",
+	HintBg:      "233",
+	Directory:   "33",
+	SelectionBg: "220",
+	SelectionFg: "0",
+	Confirm:     "166",
+	Warning:     "202",
+	Error:       "196",
+	ErrorBar:    "124",
+	Preview:     "205",
+}
+
 var (
 	// Styles
-	docStyle             = lipgloss.NewStyle().Margin(1, 2)
-	activeStyle          = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), true).BorderForeground(lipgloss.Color("63"))
-	inactiveStyle        = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), true).BorderForeground(lipgloss.Color("240"))
-	cursorStyle          = lipgloss.NewStyle().Background(lipgloss.Color("63")).Foreground(lipgloss.Color("255"))
-	selectionStyle       = lipgloss.NewStyle().Background(lipgloss.Color("220")).Foreground(lipgloss.Color("0"))
-	dirStyle             = lipgloss.NewStyle().Foreground(lipgloss.Color("33"))
-	fileStyle            = lipgloss.NewStyle().Foreground(lipgloss.Color("255"))
-	statusBar            = lipgloss.NewStyle().Background(lipgloss.Color("235")).Foreground(lipgloss.Color("250")).Padding(0, 1)
-	statusBarActive      = lipgloss.NewStyle().Background(lipgloss.Color("63")).Foreground(lipgloss.Color("255")).Padding(0, 1)
-	inputPromptStyle     = lipgloss.NewStyle().Background(lipgloss.Color("235")).Foreground(lipgloss.Color("255")).Padding(0, 1)
-	confirmPromptStyle   = lipgloss.NewStyle().Background(lipgloss.Color("166")).Foreground(lipgloss.Color("255")).Padding(0, 1)
-	overwritePromptStyle = lipgloss.NewStyle().Background(lipgloss.Color("202")).Foreground(lipgloss.Color("0")).Padding(0, 1)
-	previewStyle         = lipgloss.NewStyle().Border(lipgloss.DoubleBorder(), true).BorderForeground(lipgloss.Color("205")).Padding(1, 2)
+	docStyle             lipgloss.Style
+	activeStyle          lipgloss.Style
+	inactiveStyle        lipgloss.Style
+	cursorStyle          lipgloss.Style
+	selectionStyle       lipgloss.Style
+	dirStyle             lipgloss.Style
+	errorBarStyle        lipgloss.Style
+	errorStyle           lipgloss.Style
+	trashInfoStyle       lipgloss.Style
+	fileStyle            lipgloss.Style
+	statusBar            lipgloss.Style
+	statusBarActive      lipgloss.Style
+	inputPromptStyle     lipgloss.Style
+	inputCursorStyle     lipgloss.Style
+	inputSelectionStyle  lipgloss.Style
+	renameRowStyle       lipgloss.Style
+	confirmPromptStyle   lipgloss.Style
+	overwritePromptStyle lipgloss.Style
+	progressStyle        lipgloss.Style
+	progressFillStyle    lipgloss.Style
+	previewStyle         lipgloss.Style
+	matchStyle           lipgloss.Style
 
 	// Hint Styles
-	modifierStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Padding(0, 1)
-	modifierActiveStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("63")).Bold(true).Padding(0, 1) // Chips are rectangular in terminal usually
+	modifierStyle       lipgloss.Style
+	modifierActiveStyle lipgloss.Style
 
 	// Chip styles
+	altChipStyle         lipgloss.Style
+	altChipInactiveStyle lipgloss.Style
+
+	hintKeyStyle  lipgloss.Style
+	hintDescStyle lipgloss.Style
+	hintCardStyle lipgloss.Style
+)
+
+func init() {
+	buildStyles(defaultColors, false)
+}
+
+// fields lists the colors by their name in the config file.
+func (c *Colors) fields() []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"accent", &c.Accent}, {"accent_text", &c.AccentText}, {"cursor_text", &c.CursorText},
+		{"text", &c.Text}, {"muted", &c.Muted}, {"dim", &c.Dim}, {"border", &c.Border},
+		{"bar", &c.Bar}, {"hint_bg", &c.HintBg}, {"directory", &c.Directory},
+		{"selection_bg", &c.SelectionBg}, {"selection_fg", &c.SelectionFg}, {"confirm", &c.Confirm},
+		{"warning", &c.Warning}, {"error", &c.Error}, {"error_bar", &c.ErrorBar}, {"preview", &c.Preview},
+	}
+}
+
+// over returns base with the colors set in c replacing its own.
+func (c Colors) over(base Colors) Colors {
+	set := c.fields()
+	for i, field := range base.fields() {
+		if v := *set[i].value; v != "" {
+			*field.value = v
+		}
+	}
+	return base
+}
+
+// buildStyles sets every style from the colors c. With mono set, the cursor,
+// selection, prompts and active pane are told apart by attributes instead.
+func buildStyles(c Colors, mono bool) {
+	color := func(name string) lipgloss.TerminalColor {
+		if name == "" {
+			return lipgloss.NoColor{}
+		}
+		return lipgloss.Color(name)
+	}
+
+	docStyle = lipgloss.NewStyle().Margin(1, 2)
+	activeStyle = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), true).BorderForeground(color(c.Accent))
+	inactiveStyle = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), true).BorderForeground(color(c.Border))
+	cursorStyle = lipgloss.NewStyle().Background(color(c.Accent)).Foreground(color(c.CursorText))
+	selectionStyle = lipgloss.NewStyle().Background(color(c.SelectionBg)).Foreground(color(c.SelectionFg))
+	dirStyle = lipgloss.NewStyle().Foreground(color(c.Directory))
+	errorBarStyle = lipgloss.NewStyle().Background(color(c.ErrorBar)).Foreground(color(c.Text)).Padding(0, 1)
+	errorStyle = lipgloss.NewStyle().Foreground(color(c.Error))
+	trashInfoStyle = lipgloss.NewStyle().Foreground(color(c.Dim))
+	fileStyle = lipgloss.NewStyle().Foreground(color(c.Text))
+	statusBar = lipgloss.NewStyle().Background(color(c.Bar)).Foreground(color(c.Muted)).Padding(0, 1)
+	statusBarActive = lipgloss.NewStyle().Background(color(c.Accent)).Foreground(color(c.CursorText)).Padding(0, 1)
+	inputPromptStyle = lipgloss.NewStyle().Background(color(c.Bar)).Foreground(color(c.Text)).Padding(0, 1)
+	inputCursorStyle = lipgloss.NewStyle().Reverse(true)
+	inputSelectionStyle = lipgloss.NewStyle().Background(color(c.Accent)).Foreground(color(c.CursorText))
+	renameRowStyle = lipgloss.NewStyle().Background(color(c.Bar)).Foreground(color(c.Text))
+	confirmPromptStyle = lipgloss.NewStyle().Background(color(c.Confirm)).Foreground(color(c.Text)).Padding(0, 1)
+	overwritePromptStyle = lipgloss.NewStyle().Background(color(c.Warning)).Foreground(color(c.AccentText)).Padding(0, 1)
+	progressStyle = lipgloss.NewStyle().Background(color(c.Bar)).Foreground(color(c.Muted)).Padding(0, 1)
+	progressFillStyle = lipgloss.NewStyle().Foreground(color(c.Accent))
+	previewStyle = lipgloss.NewStyle().Border(lipgloss.DoubleBorder(), true).BorderForeground(color(c.Preview)).Padding(1, 2)
+	matchStyle = lipgloss.NewStyle().Foreground(color(c.Accent)).Bold(true)
+
+	modifierStyle = lipgloss.NewStyle().Foreground(color(c.Dim)).Padding(0, 1)
+	modifierActiveStyle = lipgloss.NewStyle().Foreground(color(c.AccentText)).Background(color(c.Accent)).Bold(true).Padding(0, 1) // Chips are rectangular in terminal usually
+
 	altChipStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("0")).
-			Background(lipgloss.Color("63")). // Blue-ish background for active state
-			Bold(true).
-			Padding(0, 1).
-			MarginRight(1)
+		Foreground(color(c.AccentText)).
+		Background(color(c.Accent)). // Accent background for active state
+		Bold(true).
+		Padding(0, 1).
+		MarginRight(1)
 
 	altChipInactiveStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("250")).
-				Background(lipgloss.Color("235")).
-				Padding(0, 1).
-				MarginRight(1)
+		Foreground(color(c.Muted)).
+		Background(color(c.Bar)).
+		Padding(0, 1).
+		MarginRight(1)
 
-	hintKeyStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Bold(true).Background(lipgloss.Color("235")).Padding(0, 1)
-	hintDescStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("250")).Background(lipgloss.Color("233")).Padding(0, 1)
+	hintKeyStyle = lipgloss.NewStyle().Foreground(color(c.Accent)).Bold(true).Background(color(c.Bar)).Padding(0, 1)
+	hintDescStyle = lipgloss.NewStyle().Foreground(color(c.Muted)).Background(color(c.HintBg)).Padding(0, 1)
 	hintCardStyle = lipgloss.NewStyle().
 		// Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("238")).
+		BorderForeground(color(c.Border)).
 		Padding(1, 1).
 		MarginRight(1)
-)
+
+	if mono {
+		activeStyle = activeStyle.Border(lipgloss.ThickBorder(), true)
+		cursorStyle = cursorStyle.Reverse(true)
+		selectionStyle = selectionStyle.Bold(true).Underline(true)
+		dirStyle = dirStyle.Bold(true)
+		errorBarStyle = errorBarStyle.Reverse(true).Bold(true)
+		errorStyle = errorStyle.Bold(true)
+		statusBarActive = statusBarActive.Reverse(true)
+		inputPromptStyle = inputPromptStyle.Reverse(true)
+		inputSelectionStyle = inputSelectionStyle.Underline(true)
+		renameRowStyle = renameRowStyle.Underline(true)
+		confirmPromptStyle = confirmPromptStyle.Reverse(true).Bold(true)
+		overwritePromptStyle = overwritePromptStyle.Reverse(true).Bold(true)
+		progressFillStyle = progressFillStyle.Bold(true)
+		matchStyle = matchStyle.Underline(true)
+		altChipStyle = altChipStyle.Reverse(true)
+		modifierActiveStyle = modifierActiveStyle.Reverse(true)
+	}
+}
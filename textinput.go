@@ -0,0 +1,478 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxHistory is the number of entries kept in each prompt's history.
+const maxHistory = 100
+
+// inputHistory holds the values previously entered in a prompt, oldest first.
+type inputHistory struct {
+	entries []string
+}
+
+// add records value as the most recent entry, moving it up if it was already there.
+func (h *inputHistory) add(value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	for i, e := range h.entries {
+		if e == value {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, value)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+}
+
+// textInput is a single-line editor with a cursor and an optional selection.
+type textInput struct {
+	value  []rune
+	cursor int // Position of the cursor, between 0 and len(value)
+	anchor int // Other end of the selection, or -1 if nothing is selected
+
+	history    *inputHistory // Values entered earlier, browsed with up and down
+	historyPos int           // Entry being shown, len(history.entries) while editing a new value
+	draft      []rune        // The new value, kept while browsing the history
+
+	completeDir   string   // Directory relative paths are completed against, empty to disable Tab completion
+	completeWords bool     // Complete the word before the cursor rather than the whole text, as in a command line
+	completions   []string // Candidates being cycled through by repeated Tabs
+	completionIdx int
+}
+
+// newTextInput returns an editor holding value with the cursor at the end.
+func newTextInput(value string) textInput {
+	r := []rune(value)
+	return textInput{value: r, cursor: len(r), anchor: -1}
+}
+
+// withHistory lets the user browse h with up and down.
+func (t textInput) withHistory(h *inputHistory) textInput {
+	t.history = h
+	t.historyPos = len(h.entries)
+	return t
+}
+
+// withCompletion enables Tab completion of paths relative to dir.
+func (t textInput) withCompletion(dir string) textInput {
+	t.completeDir = dir
+	return t
+}
+
+// withWordCompletion enables Tab completion of the word before the cursor
+// as a path relative to dir.
+func (t textInput) withWordCompletion(dir string) textInput {
+	t.completeDir = dir
+	t.completeWords = true
+	return t
+}
+
+// commit adds the edited text to the history.
+func (t *textInput) commit() {
+	if t.history != nil {
+		t.history.add(t.Value())
+	}
+}
+
+// Value returns the edited text.
+func (t textInput) Value() string {
+	return string(t.value)
+}
+
+// selectRange selects the runes between start and end and puts the cursor at end.
+func (t *textInput) selectRange(start, end int) {
+	t.anchor = clamp(start, 0, len(t.value))
+	t.cursor = clamp(end, 0, len(t.value))
+	if t.anchor == t.cursor {
+		t.anchor = -1
+	}
+}
+
+// selection returns the selected range, or ok == false if nothing is selected.
+func (t textInput) selection() (start, end int, ok bool) {
+	if t.anchor < 0 || t.anchor == t.cursor {
+		return 0, 0, false
+	}
+	return min(t.anchor, t.cursor), max(t.anchor, t.cursor), true
+}
+
+// deleteSelection removes the selected runes and reports whether there were any.
+func (t *textInput) deleteSelection() bool {
+	start, end, ok := t.selection()
+	if !ok {
+		return false
+	}
+	t.value = append(t.value[:start:start], t.value[end:]...)
+	t.cursor = start
+	t.anchor = -1
+	return true
+}
+
+// insert replaces the selection, if any, with s.
+func (t *textInput) insert(s []rune) {
+	t.deleteSelection()
+	value := make([]rune, 0, len(t.value)+len(s))
+	value = append(value, t.value[:t.cursor]...)
+	value = append(value, s...)
+	value = append(value, t.value[t.cursor:]...)
+	t.value = value
+	t.cursor += len(s)
+}
+
+// moveTo moves the cursor to pos, extending the selection if extend is set.
+func (t *textInput) moveTo(pos int, extend bool) {
+	if extend {
+		if t.anchor < 0 {
+			t.anchor = t.cursor
+		}
+	} else {
+		t.anchor = -1
+	}
+	t.cursor = clamp(pos, 0, len(t.value))
+}
+
+// setValue replaces the text and puts the cursor at the end.
+func (t *textInput) setValue(value []rune) {
+	t.value = append([]rune(nil), value...)
+	t.cursor = len(t.value)
+	t.anchor = -1
+}
+
+// isWordRune reports whether r is part of a word for word-wise movement and deletion.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// wordStart returns the start of the word before pos.
+func (t textInput) wordStart(pos int) int {
+	for pos > 0 && !isWordRune(t.value[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(t.value[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the end of the word after pos.
+func (t textInput) wordEnd(pos int) int {
+	for pos < len(t.value) && !isWordRune(t.value[pos]) {
+		pos++
+	}
+	for pos < len(t.value) && isWordRune(t.value[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// deleteRange removes the runes between start and end.
+func (t *textInput) deleteRange(start, end int) {
+	start, end = clamp(start, 0, len(t.value)), clamp(end, 0, len(t.value))
+	if start >= end {
+		return
+	}
+	t.value = append(t.value[:start:start], t.value[end:]...)
+	t.cursor = start
+	t.anchor = -1
+}
+
+// sanitize turns pasted text into a single line: line breaks and tabs become
+// spaces and other control characters are dropped.
+func sanitize(runes []rune) []rune {
+	// A trailing line break usually comes along with copied text
+	for len(runes) > 0 && (runes[len(runes)-1] == '\n' || runes[len(runes)-1] == '\r') {
+		runes = runes[:len(runes)-1]
+	}
+	out := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch {
+		case r == '\r' && i+1 < len(runes) && runes[i+1] == '\n':
+			// Part of a CRLF, the LF becomes the space
+		case r == '\n' || r == '\r' || r == '\t':
+			out = append(out, ' ')
+		case unicode.IsControl(r):
+		default:
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// browseHistory shows the entry delta steps away from the current one.
+func (t *textInput) browseHistory(delta int) {
+	if t.history == nil {
+		return
+	}
+	pos := clamp(t.historyPos+delta, 0, len(t.history.entries))
+	if pos == t.historyPos {
+		return
+	}
+	if t.historyPos == len(t.history.entries) {
+		t.draft = append([]rune(nil), t.value...)
+	}
+	t.historyPos = pos
+	if pos == len(t.history.entries) {
+		t.setValue(t.draft)
+	} else {
+		t.setValue([]rune(t.history.entries[pos]))
+	}
+}
+
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// pathCandidates returns the completions of the path prefix, with a trailing
+// slash on directories. Hidden entries are only offered once a dot is typed.
+func pathCandidates(dir, prefix string) []string {
+	head, base := "", prefix
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		head, base = prefix[:i+1], prefix[i+1:]
+	}
+	listDir := expandHome(head)
+	if listDir == "" {
+		listDir = "."
+	}
+	if !filepath.IsAbs(listDir) {
+		listDir = filepath.Join(dir, listDir)
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(listDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			name += "/"
+		}
+		candidates = append(candidates, head+name)
+	}
+	return candidates
+}
+
+// commonPrefix returns the longest prefix shared by all of values.
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// complete completes the path before the cursor. A unique match is filled in,
+// several matches are first completed to their common prefix and then cycled
+// through by further presses, backwards if back is set.
+func (t *textInput) complete(back bool) {
+	if len(t.completions) > 0 {
+		step := 1
+		if back {
+			step = len(t.completions) - 1
+		}
+		t.completionIdx = (t.completionIdx + step) % len(t.completions)
+		t.replaceBeforeCursor(t.completions[t.completionIdx])
+		return
+	}
+	prefix := string(t.value[t.completionStart():t.cursor])
+	candidates := pathCandidates(t.completeDir, prefix)
+	switch {
+	case len(candidates) == 0:
+	case len(candidates) == 1:
+		t.replaceBeforeCursor(candidates[0])
+	case len(commonPrefix(candidates)) > len(prefix):
+		t.replaceBeforeCursor(commonPrefix(candidates))
+	default:
+		t.completions = candidates
+		t.completionIdx = 0
+		if back {
+			t.completionIdx = len(candidates) - 1
+		}
+		t.replaceBeforeCursor(candidates[t.completionIdx])
+	}
+}
+
+// completionStart returns where the text being completed starts.
+func (t *textInput) completionStart() int {
+	if !t.completeWords {
+		return 0
+	}
+	start := t.cursor
+	for start > 0 && t.value[start-1] != ' ' {
+		start--
+	}
+	return start
+}
+
+// replaceBeforeCursor replaces the text being completed with s.
+func (t *textInput) replaceBeforeCursor(s string) {
+	start := t.completionStart()
+	rest := t.value[t.cursor:]
+	r := []rune(s)
+	t.value = append(append(slices.Clone(t.value[:start]), r...), rest...)
+	t.cursor = start + len(r)
+	t.anchor = -1
+}
+
+// update applies an editing key and reports whether the key was handled.
+func (t *textInput) update(msg tea.KeyMsg) bool {
+	key := msg.String()
+	if key != "tab" && key != "shift+tab" {
+		t.completions = nil
+	}
+	if msg.Paste {
+		t.insert(sanitize(msg.Runes))
+		return true
+	}
+	switch key {
+	case "tab", "shift+tab":
+		if t.completeDir == "" {
+			return false
+		}
+		t.complete(key == "shift+tab")
+	case "up":
+		if t.history == nil {
+			return false
+		}
+		t.browseHistory(-1)
+	case "down":
+		if t.history == nil {
+			return false
+		}
+		t.browseHistory(1)
+	case "ctrl+left", "alt+left", "alt+b":
+		t.moveTo(t.wordStart(t.cursor), false)
+	case "ctrl+right", "alt+right", "alt+f":
+		t.moveTo(t.wordEnd(t.cursor), false)
+	case "ctrl+shift+left":
+		t.moveTo(t.wordStart(t.cursor), true)
+	case "ctrl+shift+right":
+		t.moveTo(t.wordEnd(t.cursor), true)
+	case "ctrl+w":
+		// Like the shell: delete back to the previous space
+		if !t.deleteSelection() {
+			start := t.cursor
+			for start > 0 && t.value[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && t.value[start-1] != ' ' {
+				start--
+			}
+			t.deleteRange(start, t.cursor)
+		}
+	case "alt+backspace", "ctrl+backspace":
+		if !t.deleteSelection() {
+			t.deleteRange(t.wordStart(t.cursor), t.cursor)
+		}
+	case "alt+delete", "alt+d":
+		if !t.deleteSelection() {
+			t.deleteRange(t.cursor, t.wordEnd(t.cursor))
+		}
+	case "ctrl+u":
+		t.deleteRange(0, t.cursor)
+	case "ctrl+k":
+		t.deleteRange(t.cursor, len(t.value))
+	case "ctrl+a":
+		t.moveTo(0, false)
+	case "ctrl+e":
+		t.moveTo(len(t.value), false)
+	case "left":
+		if start, _, ok := t.selection(); ok {
+			t.moveTo(start, false)
+		} else {
+			t.moveTo(t.cursor-1, false)
+		}
+	case "right":
+		if _, end, ok := t.selection(); ok {
+			t.moveTo(end, false)
+		} else {
+			t.moveTo(t.cursor+1, false)
+		}
+	case "shift+left":
+		t.moveTo(t.cursor-1, true)
+	case "shift+right":
+		t.moveTo(t.cursor+1, true)
+	case "home":
+		t.moveTo(0, false)
+	case "end":
+		t.moveTo(len(t.value), false)
+	case "shift+home":
+		t.moveTo(0, true)
+	case "shift+end":
+		t.moveTo(len(t.value), true)
+	case "backspace":
+		if !t.deleteSelection() && t.cursor > 0 {
+			t.value = append(t.value[:t.cursor-1:t.cursor-1], t.value[t.cursor:]...)
+			t.cursor--
+		}
+	case "delete":
+		if !t.deleteSelection() && t.cursor < len(t.value) {
+			t.value = append(t.value[:t.cursor:t.cursor], t.value[t.cursor+1:]...)
+		}
+	default:
+		switch msg.Type {
+		case tea.KeyRunes:
+			if msg.Alt {
+				return false
+			}
+			t.insert(msg.Runes)
+		case tea.KeySpace:
+			t.insert([]rune{' '})
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// View renders the text with the selection highlighted and the cursor shown as a reversed cell.
+func (t textInput) View() string {
+	start, end, hasSelection := t.selection()
+	var b strings.Builder
+	for i := 0; i <= len(t.value); i++ {
+		ch := " " // The cursor can sit after the last rune
+		if i < len(t.value) {
+			ch = string(t.value[i])
+		}
+		switch {
+		case i == t.cursor:
+			b.WriteString(inputCursorStyle.Render(ch))
+		case hasSelection && i >= start && i < end:
+			b.WriteString(inputSelectionStyle.Render(ch))
+		case i < len(t.value):
+			b.WriteString(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// theme is a named set of colors.
+type theme struct {
+	name   string
+	colors Colors // Colors for terminals with 256 colors or more
+	basic  Colors // Colors for 16-color terminals, the same as colors if empty
+	mono   bool   // Show states with bold, underline and reverse instead of colors
+}
+
+// basicDark and basicLight stick to the 16 ANSI colors, whose actual look is
+// up to the terminal's palette.
+var (
+	basicDark = Colors{
+		Accent: "4", AccentText: "0", CursorText: "15", Text: "15", Muted: "7", Dim: "8",
+		Border: "8", Bar: "0", HintBg: "0", Directory: "12", SelectionBg: "3", SelectionFg: "0",
+		Confirm: "1", Warning: "3", Error: "9", ErrorBar: "1", Preview: "5",
+	}
+	basicLight = Colors{
+		Accent: "4", AccentText: "15", CursorText: "15", Text: "0", Muted: "8", Dim: "8",
+		Border: "7", Bar: "7", HintBg: "15", Directory: "4", SelectionBg: "3", SelectionFg: "0",
+		Confirm: "1", Warning: "3", Error: "1", ErrorBar: "1", Preview: "5",
+	}
+)
+
+// themes are the bundled themes, in the order the Theme command cycles through them.
+var themes = []theme{
+	{name: "dark", colors: defaultColors, basic: basicDark},
+	{
+		name: "light",
+		colors: Colors{
+			Accent: "26", AccentText: "255", CursorText: "255", Text: "235", Muted: "240", Dim: "245",
+			Border: "250", Bar: "253", HintBg: "255", Directory: "25", SelectionBg: "222", SelectionFg: "0",
+			Confirm: "216", Warning: "208", Error: "160", ErrorBar: "217", Preview: "127",
+		},
+		basic: basicLight,
+	},
+	{
+		name: "solarized",
+		colors: Colors{
+			Accent: "#268bd2", AccentText: "#002b36", CursorText: "#fdf6e3", Text: "#eee8d5", Muted: "#93a1a1", Dim: "#586e75",
+			Border: "#586e75", Bar: "#073642", HintBg: "#002b36", Directory: "#2aa198", SelectionBg: "#b58900", SelectionFg: "#002b36",
+			Confirm: "#cb4b16", Warning: "#b58900", Error: "#dc322f", ErrorBar: "#dc322f", Preview: "#d33682",
+		},
+		basic: basicDark,
+	},
+	{
+		name: "high-contrast",
+		colors: Colors{
+			Accent: "11", AccentText: "0", CursorText: "0", Text: "15", Muted: "15", Dim: "7",
+			Border: "7", Bar: "0", HintBg: "0", Directory: "14", SelectionBg: "15", SelectionFg: "0",
+			Confirm: "9", Warning: "11", Error: "9", ErrorBar: "1", Preview: "13",
+		},
+	},
+	{name: "monochrome", mono: true},
+}
+
+// findTheme returns the index of the theme called name.
+func findTheme(name string) (int, bool) {
+	for i, t := range themes {
+		if t.name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// terminal is what the terminal can show.
+type terminal struct {
+	noColor bool // No colors at all, because of NO_COLOR or a dumb terminal
+	basic   bool // Only the 16 ANSI colors
+	dark    bool // The background is dark
+}
+
+// detectTerminal asks the terminal about its colors. It has to run before
+// the program starts reading input, as the background is queried with an
+// escape sequence.
+func detectTerminal() terminal {
+	profile := lipgloss.ColorProfile()
+	return terminal{
+		noColor: profile == termenv.Ascii || os.Getenv("NO_COLOR") != "",
+		basic:   profile == termenv.ANSI,
+		dark:    lipgloss.HasDarkBackground(),
+	}
+}
+
+// pickTheme returns the index of the configured theme. "auto" picks dark or
+// light to suit the terminal's background.
+func pickTheme(name string, term terminal) (int, error) {
+	if name == "auto" {
+		name = "dark"
+		if !term.dark {
+			name = "light"
+		}
+	}
+	i, ok := findTheme(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown theme %q", name)
+	}
+	return i, nil
+}
+
+// applyTheme rebuilds the styles from theme t with the colors set in
+// overrides taking precedence. Without colors, states are shown with
+// attributes instead.
+func applyTheme(t theme, overrides Colors, term terminal) {
+	colors := t.colors
+	if term.basic && t.basic != (Colors{}) {
+		colors = t.basic
+	}
+	colors = overrides.over(colors)
+	mono := t.mono || term.noColor
+	if mono {
+		colors = Colors{}
+	}
+	buildStyles(colors, mono)
+}
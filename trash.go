@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trash support following the FreeDesktop.org Trash specification:
+// https://specifications.freedesktop.org/trash-spec/latest/
+
+// trashDateFormat is the layout of the DeletionDate key in .trashinfo files.
+const trashDateFormat = "2006-01-02T15:04:05"
+
+// trashEntry is an item sitting in a trash directory.
+type trashEntry struct {
+	TrashDir     string // The trash directory holding the item (containing files/ and info/)
+	Name         string // Name of the item under files/
+	OriginalPath string
+	DeletionDate time.Time
+}
+
+// filePath returns where the trashed item is stored.
+func (e trashEntry) filePath() string {
+	return filepath.Join(e.TrashDir, "files", e.Name)
+}
+
+// infoPath returns the path of the item's .trashinfo file.
+func (e trashEntry) infoPath() string {
+	return filepath.Join(e.TrashDir, "info", e.Name+".trashinfo")
+}
+
+// homeTrashDir returns $XDG_DATA_HOME/Trash.
+func homeTrashDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// mountTopDir returns the top directory of the filesystem holding path.
+func mountTopDir(path string) (string, error) {
+	dev, err := deviceOf(path)
+	if err != nil {
+		return "", err
+	}
+	dir := path
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentDev, err := deviceOf(parent)
+		if err != nil || parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// trashDirFor picks the trash directory for path: the home trash if path is on
+// the same filesystem, otherwise $topdir/.Trash/$uid or $topdir/.Trash-$uid.
+// It also returns the directory that the stored original path is relative to,
+// which is empty when absolute paths are stored.
+func trashDirFor(path string) (trashDir, relativeTo string, err error) {
+	home, err := homeTrashDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return "", "", err
+	}
+	pathDev, err := deviceOf(filepath.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	if homeDev, err := deviceOf(home); err == nil && homeDev == pathDev {
+		return home, "", nil
+	}
+
+	topDir, err := mountTopDir(filepath.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// The shared $topdir/.Trash must be a real directory with the sticky bit set
+	shared := filepath.Join(topDir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, topDir, nil
+		}
+	}
+
+	dir := filepath.Join(topDir, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("no usable trash directory on %s: %w", topDir, err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return "", "", fmt.Errorf("no usable trash directory on %s", topDir)
+	}
+	return dir, topDir, nil
+}
+
+// trashFile moves path into the trash and returns its entry.
+func trashFile(path string) (trashEntry, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return trashEntry{}, err
+	}
+	trashDir, relativeTo, err := trashDirFor(path)
+	if err != nil {
+		return trashEntry{}, err
+	}
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trashDir, sub), 0700); err != nil {
+			return trashEntry{}, err
+		}
+	}
+
+	storedPath := path
+	if relativeTo != "" {
+		if rel, err := filepath.Rel(relativeTo, path); err == nil {
+			storedPath = rel
+		}
+	}
+	entry := trashEntry{TrashDir: trashDir, OriginalPath: path, DeletionDate: time.Now()}
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: storedPath}).EscapedPath(), entry.DeletionDate.Format(trashDateFormat))
+
+	// Reserve a unique name by creating the .trashinfo file exclusively
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		entry.Name = base
+		if i > 1 {
+			entry.Name = fmt.Sprintf("%s.%d", base, i)
+		}
+		f, err := os.OpenFile(entry.infoPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return trashEntry{}, err
+		}
+		_, err = f.WriteString(info)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			if _, err = os.Lstat(entry.filePath()); err == nil {
+				os.Remove(entry.infoPath()) // A stray file without info; try the next name
+				continue
+			}
+			err = os.Rename(path, entry.filePath())
+		}
+		if err != nil {
+			os.Remove(entry.infoPath())
+			return trashEntry{}, err
+		}
+		return entry, nil
+	}
+}
+
+// trashDirs returns the trash directories that currently exist: the home
+// trash and the per-user trashes at the top of every mounted filesystem.
+func trashDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	if home, err := homeTrashDir(); err == nil {
+		dirs = append(dirs, home)
+		seen[home] = true
+	}
+	uid := strconv.Itoa(os.Getuid())
+	for _, topDir := range mountPoints() {
+		for _, dir := range []string{filepath.Join(topDir, ".Trash", uid), filepath.Join(topDir, ".Trash-"+uid)} {
+			if seen[dir] {
+				continue // The same filesystem can be mounted more than once
+			}
+			if info, err := os.Lstat(filepath.Join(dir, "info")); err == nil && info.IsDir() {
+				dirs = append(dirs, dir)
+				seen[dir] = true
+			}
+		}
+	}
+	return dirs
+}
+
+// listTrash returns every item in the trash, most recently deleted first.
+func listTrash() []trashEntry {
+	var entries []trashEntry
+	for _, dir := range trashDirs() {
+		infos, err := os.ReadDir(filepath.Join(dir, "info"))
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name, ok := strings.CutSuffix(info.Name(), ".trashinfo")
+			if !ok {
+				continue
+			}
+			entry, err := readTrashInfo(dir, name)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletionDate.After(entries[j].DeletionDate)
+	})
+	return entries
+}
+
+// readTrashInfo parses the .trashinfo file of the item name in trashDir.
+func readTrashInfo(trashDir, name string) (trashEntry, error) {
+	entry := trashEntry{TrashDir: trashDir, Name: name}
+	f, err := os.Open(entry.infoPath())
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[Trash Info]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inSection || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			path, err := url.PathUnescape(value)
+			if err != nil {
+				return entry, err
+			}
+			if !filepath.IsAbs(path) {
+				// Relative paths are relative to the top of the filesystem holding the trash
+				topDir, err := mountTopDir(trashDir)
+				if err != nil {
+					return entry, err
+				}
+				path = filepath.Join(topDir, path)
+			}
+			entry.OriginalPath = path
+		case "DeletionDate":
+			entry.DeletionDate, _ = time.ParseInLocation(trashDateFormat, value, time.Local)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entry, err
+	}
+	if entry.OriginalPath == "" {
+		return entry, fmt.Errorf("%s: missing Path", entry.infoPath())
+	}
+	return entry, nil
+}
+
+// restoreTrashed moves a trashed item back to its original location.
+func restoreTrashed(entry trashEntry) error {
+	if _, err := os.Lstat(entry.OriginalPath); err == nil {
+		return fmt.Errorf("%s already exists", entry.OriginalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(entry.filePath(), entry.OriginalPath); err != nil {
+		return err
+	}
+	return os.Remove(entry.infoPath())
+}
+
+// purgeTrashed removes a trashed item for good.
+func purgeTrashed(entry trashEntry) error {
+	if err := os.RemoveAll(entry.filePath()); err != nil {
+		return err
+	}
+	return os.Remove(entry.infoPath())
+}
+
+// trashListing turns trash entries into pane entries, named after the
+// original files, and indexes the entries by their path in the trash.
+func trashListing(entries []trashEntry) ([]file, map[string]trashEntry) {
+	var files []file
+	byPath := make(map[string]trashEntry)
+	for _, e := range entries {
+		info, err := os.Lstat(e.filePath())
+		if err != nil {
+			continue // Info file without its item
+		}
+		f := file{
+			Name:    filepath.Base(e.OriginalPath),
+			Path:    e.filePath(),
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		}
+		files = append(files, f)
+		byPath[f.Path] = e
+	}
+	return files, byPath
+}
+
+// trashEntriesFor returns the trash entries behind files listed in the trash view.
+func (p pane) trashEntriesFor(files []file) []trashEntry {
+	var entries []trashEntry
+	for _, f := range files {
+		if e, ok := p.trash[f.Path]; ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -18,28 +21,58 @@ func ensureCursorInBounds(p *pane) {
 	}
 }
 
-// Update handles messages and updates the model.
-func (m *model) processOverwriteConflicts() tea.Cmd {
-	if m.skipAll {
-		m.overwriteConflicts = nil // Skip all remaining
+// processOverwriteConflicts advances the overwrite prompt and, once every
+// conflict of the current job has been decided, queues the job.
+func (m *model) processOverwriteConflicts() {
+	j := m.conflictJobs[0]
+	if len(j.conflicts) > 0 {
+		return
 	}
 
-	if len(m.overwriteConflicts) == 0 {
-		m.isConfirmingOverwrite = false
-		m.overwriteAll = false
-		m.skipAll = false
-		return nil
+	m.conflictJobs = m.conflictJobs[1:]
+	m.isConfirmingOverwrite = len(m.conflictJobs) > 0
+	if len(j.files) > 0 {
+		m.jobs.add(j)
 	}
+}
 
-	var filesToOperate []file
-	for _, conflict := range m.overwriteConflicts {
-		filesToOperate = append(filesToOperate, conflict.Source)
+// deleteFiles deletes the files the delete prompt was about.
+func (m *model) deleteFiles() tea.Cmd {
+	activePane := m.activePane()
+	m.isDeleting = false
+	var cmd tea.Cmd
+	switch {
+	case activePane.listing == listingTrash:
+		cmd = purgeTrashCmd(activePane.trashEntriesFor(m.filesToDelete))
+	case m.deletePermanently:
+		cmd = deleteFilesCmd(m.filesToDelete)
+	default:
+		cmd = trashFilesCmd(m.filesToDelete)
 	}
+	m.filesToDelete = nil                           // Clear files to delete
+	activePane.selected = make(map[string]struct{}) // Clear selection
+	return cmd
+}
 
-	if m.isMoving {
-		return moveFilesCmd(filesToOperate, filepath.Dir(m.overwriteConflicts[0].Destination), true)
+// queueJob queues j, or holds it back until its conflicts are resolved.
+func (m *model) queueJob(j *job) {
+	switch m.confirm.Overwrite {
+	case "overwrite":
+		for _, c := range j.conflicts {
+			j.files = append(j.files, c.Source)
+		}
+		j.conflicts = nil
+	case "skip":
+		j.conflicts = nil
+	}
+	if len(j.conflicts) > 0 {
+		m.conflictJobs = append(m.conflictJobs, j)
+		m.isConfirmingOverwrite = true
+		return
+	}
+	if len(j.files) > 0 {
+		m.jobs.add(j)
 	}
-	return copyFilesCmd(filesToOperate, filepath.Dir(m.overwriteConflicts[0].Destination), true)
 }
 
 // Update handles messages and updates the model.
@@ -53,55 +86,188 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// }
 	// User requested to remove this functionality for now.
 
+	// An error stays in the status bar until the next key press
+	var action string
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.err = nil
+		var pending bool
+		if action, pending = m.resolveKey(msg); pending {
+			return m, nil
+		}
+	}
+	if action == "force_quit" {
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	// A command picked in the palette runs as if its key had been pressed
+	if msg, ok := msg.(tea.KeyMsg); ok && m.palette != nil {
+		switch action {
+		case "prompt_cancel":
+			m.palette = nil
+			return m, nil
+		case "prompt_confirm":
+			cmd, ok := m.palette.selected()
+			m.palette = nil
+			if !ok {
+				return m, nil
+			}
+			action = cmd
+		default:
+			m.palette.update(msg, m.paletteRows())
+			return m, nil
+		}
+	}
+
 	// Handle operations that take precedence over normal key presses
-	if m.isCreatingFolder {
+	if m.prompt != nil {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			key := msg.String()
-			if mapKey, ok := m.aliasMap[key]; ok {
-				key = mapKey
-			}
-			switch key {
-			case "enter":
-				activePane := &m.leftPane
-				if m.rightPane.active {
-					activePane = &m.rightPane
+			switch action {
+			case "prompt_confirm":
+				return m.submitPrompt()
+			case "prompt_terminal":
+				if m.prompt.kind == promptCommand {
+					return m.runCommandLine(true)
 				}
-				m.isCreatingFolder = false
-				cmd = createFolderCmd(filepath.Join(activePane.path, m.folderNameInput))
-				m.folderNameInput = ""
-				return m, cmd
-			case "esc":
-				m.isCreatingFolder = false
-				m.folderNameInput = ""
 				return m, nil
-			case "backspace":
-				if len(m.folderNameInput) > 0 {
-					m.folderNameInput = m.folderNameInput[:len(m.folderNameInput)-1]
-				}
+			case "prompt_cancel":
+				m.prompt = nil
+				m.promptErr = nil
 				return m, nil
 			default:
-				if len(msg.String()) == 1 {
-					m.folderNameInput += msg.String()
+				if m.prompt.input.update(msg) {
+					m.promptErr = nil
 				}
 				return m, nil
 			}
 		}
-	} else if m.isDeleting {
+	} else if m.activePane().renameInput != nil {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
-			case "y", "Y":
-				activePane := &m.leftPane
-				if m.rightPane.active {
-					activePane = &m.rightPane
+			activePane := m.activePane()
+			switch action {
+			case "prompt_confirm":
+				newName := strings.TrimSpace(activePane.renameInput.Value())
+				from := activePane.renamePath
+				switch {
+				case newName == filepath.Base(from):
+					activePane.renameInput = nil
+					m.renameErr = nil
+					return m, nil
+				case newName == "" || newName == "." || newName == ".." || strings.ContainsRune(newName, filepath.Separator):
+					m.renameErr = fmt.Errorf("invalid name %q", newName)
+					return m, nil
 				}
-				m.isDeleting = false
-				cmd = deleteFilesCmd(m.filesToDelete)
-				m.filesToDelete = nil                           // Clear files to delete
-				activePane.selected = make(map[string]struct{}) // Clear selection
-				return m, cmd
-			case "n", "N", "esc":
+				activePane.renameInput.commit()
+				return m, renameFileCmd(activePane.id, from, filepath.Join(filepath.Dir(from), newName))
+			case "prompt_cancel":
+				activePane.renameInput = nil
+				m.renameErr = nil
+				return m, nil
+			default:
+				activePane.renameInput.update(msg)
+				return m, nil
+			}
+		}
+	} else if m.activePane().filterInput != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			activePane := m.activePane()
+			switch action {
+			case "prompt_confirm":
+				activePane.filterInput.commit()
+				activePane.filterInput = nil
+			case "prompt_cancel":
+				activePane.clearFilter()
+			case "prompt_option":
+				activePane.setFilter((activePane.filter.kind+1)%filterKindCount, activePane.filterInput.Value())
+			default:
+				if activePane.filterInput.update(msg) {
+					activePane.setFilter(activePane.filter.kind, activePane.filterInput.Value())
+				}
+			}
+			return m, nil
+		}
+	} else if m.findDialog != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch action {
+			case "prompt_confirm":
+				return m, m.startFind()
+			case "prompt_cancel":
+				m.findDialog = nil
+			default:
+				m.findDialog.update(msg, action)
+			}
+			return m, nil
+		}
+	} else if m.grepDialog != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch action {
+			case "prompt_confirm":
+				return m, m.startGrep()
+			case "prompt_cancel":
+				m.grepDialog = nil
+			default:
+				m.grepDialog.update(msg, action)
+			}
+			return m, nil
+		}
+	} else if m.batchRename != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch action {
+			case "prompt_cancel":
+				m.batchRename = nil
+			case "prompt_confirm":
+				b := m.batchRename
+				if b.err != nil || b.problems() > 0 {
+					return m, nil
+				}
+				m.batchRename = nil
+				if renames := b.renames(); len(renames) > 0 {
+					return m, batchRenameCmd(b.paneID, renames)
+				}
+			default:
+				m.batchRename.update(msg, action, m.batchRenameRows())
+			}
+			return m, nil
+		}
+	} else if m.nameEdits != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case action == "prompt_confirm" || msg.String() == "y" || msg.String() == "Y":
+				edits := *m.nameEdits
+				m.nameEdits = nil
+				return m, applyNameEditsCmd(edits)
+			case action == "prompt_cancel" || msg.String() == "n" || msg.String() == "N":
+				m.nameEdits = nil
+			}
+			return m, nil
+		}
+	} else if m.pendingAction != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case action == "prompt_confirm" || msg.String() == "y" || msg.String() == "Y":
+				run := m.pendingAction.run
+				m.pendingAction = nil
+				return m, run
+			case action == "prompt_cancel" || msg.String() == "n" || msg.String() == "N":
+				m.pendingAction = nil
+			}
+			return m, nil
+		}
+	} else if m.isDeleting {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case action == "prompt_confirm" || msg.String() == "y" || msg.String() == "Y":
+				return m, m.deleteFiles()
+			case action == "prompt_cancel" || msg.String() == "n" || msg.String() == "N":
 				m.isDeleting = false
 				m.filesToDelete = nil // Clear files to delete
 				return m, nil
@@ -110,56 +276,109 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	} else if m.isConfirmingOverwrite {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
+			j := m.conflictJobs[0]
+			answer := msg.String()
+			switch action {
+			case "prompt_confirm":
+				answer = "y"
+			case "prompt_cancel":
+				answer = "esc"
+			}
+			switch answer {
 			case "y", "Y":
-				// Overwrite the current file and process the rest
-				conflict := m.overwriteConflicts[0]
-				m.overwriteConflicts = m.overwriteConflicts[1:]
-				var operationCmd tea.Cmd
-				if m.isMoving {
-					operationCmd = moveFilesCmd([]file{conflict.Source}, filepath.Dir(conflict.Destination), true)
-				} else {
-					operationCmd = copyFilesCmd([]file{conflict.Source}, filepath.Dir(conflict.Destination), true)
-				}
-				return m, tea.Sequence(operationCmd, m.processOverwriteConflicts())
+				// Overwrite the current file and ask about the rest
+				j.files = append(j.files, j.conflicts[0].Source)
+				j.conflicts = j.conflicts[1:]
+				m.processOverwriteConflicts()
+				return m, nil
 
 			case "n", "N":
-				// Skip the current file and process the rest
-				m.overwriteConflicts = m.overwriteConflicts[1:]
-				return m, m.processOverwriteConflicts()
+				// Skip the current file and ask about the rest
+				j.conflicts = j.conflicts[1:]
+				m.processOverwriteConflicts()
+				return m, nil
 
-			case "a", "A":
-				m.overwriteAll = true
-				return m, m.processOverwriteConflicts()
+			case "a", "A": // Overwrite All
+				for _, conflict := range j.conflicts {
+					j.files = append(j.files, conflict.Source)
+				}
+				j.conflicts = nil
+				m.processOverwriteConflicts()
+				return m, nil
 
 			case "s", "S": // Skip All
-				m.skipAll = true
-				return m, m.processOverwriteConflicts()
+				j.conflicts = nil
+				m.processOverwriteConflicts()
+				return m, nil
 
-			case "esc":
-				m.isConfirmingOverwrite = false
-				m.overwriteConflicts = nil
-				m.overwriteAll = false
-				m.skipAll = false
+			case "esc": // Drop the whole job
+				m.conflictJobs = m.conflictJobs[1:]
+				m.isConfirmingOverwrite = len(m.conflictJobs) > 0
 				return m, nil
 			}
 		}
+	} else if m.showJobs {
+		switch msg.(type) {
+		case tea.KeyMsg:
+			var selected *job
+			if m.jobCursor < len(m.jobs.jobs) {
+				selected = m.jobs.jobs[m.jobCursor]
+			}
+			switch action {
+			case "jobs_close":
+				m.showJobs = false
+			case "jobs_up":
+				if m.jobCursor > 0 {
+					m.jobCursor--
+				}
+			case "jobs_down":
+				if m.jobCursor < len(m.jobs.jobs)-1 {
+					m.jobCursor++
+				}
+			case "jobs_pause":
+				if selected != nil {
+					if selected.state == jobPaused {
+						m.jobs.resume(selected)
+					} else {
+						m.jobs.pause(selected)
+					}
+				}
+			case "jobs_retry":
+				if selected != nil {
+					m.jobs.retry(selected)
+				}
+			case "jobs_cancel":
+				if selected != nil {
+					m.jobs.cancel(selected)
+				}
+			case "jobs_clear":
+				m.jobs.clearFinished()
+				if m.jobCursor >= len(m.jobs.jobs) {
+					m.jobCursor = max(len(m.jobs.jobs)-1, 0)
+				}
+			}
+			// Keep the selected job in view
+			rows := m.jobRows()
+			m.jobScrollY = clamp(m.jobScrollY, max(0, m.jobCursor-rows+1), m.jobCursor)
+			return m, nil
+		}
 	} else if m.isPreviewing {
-		switch msg := msg.(type) {
+		switch msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
-			case "esc", "q":
+			switch action {
+			case "preview_close":
 				m.isPreviewing = false
 				m.previewContent = ""
 				m.previewFilePath = ""
 				m.previewScrollY = 0
+				m.previewMatch = nil
 				return m, nil
-			case "up", "k":
+			case "preview_up":
 				if m.previewScrollY > 0 {
 					m.previewScrollY--
 				}
 				return m, nil
-			case "down", "j":
+			case "preview_down":
 				// Calculate max scroll
 				innerWidth := m.previewWidth - 6
 				innerHeight := m.previewHeight - 4
@@ -173,13 +392,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.previewScrollY++
 				}
 				return m, nil
-			case "pgup":
+			case "preview_page_up":
 				m.previewScrollY -= m.previewHeight
 				if m.previewScrollY < 0 {
 					m.previewScrollY = 0
 				}
 				return m, nil
-			case "pgdown":
+			case "preview_page_down":
 				// Calculate max scroll
 				innerWidth := m.previewWidth - 6
 				innerHeight := m.previewHeight - 4
@@ -194,10 +413,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.previewScrollY = maxScroll
 				}
 				return m, nil
-			case "home", "g":
+			case "preview_top":
 				m.previewScrollY = 0
 				return m, nil
-			case "end", "G":
+			case "preview_bottom":
 				// Calculate max scroll
 				innerWidth := m.previewWidth - 6
 				innerHeight := m.previewHeight - 4
@@ -211,41 +430,40 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 	} else { // Normal operation mode
-		switch msg := msg.(type) {
+		switch msg.(type) {
 		case tea.KeyMsg:
-			key := msg.String()
-			if mapKey, ok := m.aliasMap[key]; ok {
-				key = mapKey
-			}
-			switch key {
-			case m.keyMap.Quit.Key: // Quit
-				m.quitting = true
-				return m, tea.Quit
-			case m.keyMap.ForceQuit.Key: // Force Quit
+			switch action {
+			case "quit": // Quit
 				m.quitting = true
 				return m, tea.Quit
-			case m.keyMap.SwitchPane.Key:
+			case "palette":
+				m.palette = newPalette(m.keyMap)
+				return m, nil
+			case "switch_pane":
 				m.leftPane.active = !m.leftPane.active
 				m.rightPane.active = !m.rightPane.active
 				return m, nil
-			case m.keyMap.Preview.Key: // Preview
+			case "preview": // Preview
 				activePane := &m.leftPane
 				if m.rightPane.active {
 					activePane = &m.rightPane
 				}
 				if len(activePane.files) > 0 {
 					selectedFile := activePane.files[activePane.cursor]
+					if selectedFile.Match != nil {
+						return m, m.openMatch(selectedFile)
+					}
 					if !selectedFile.IsDir {
 						m.isPreviewing = true
 						m.previewFilePath = selectedFile.Path
 						m.previewWidth = activePane.width
 						m.previewHeight = activePane.height
 						m.previewScrollY = 0
-						return m, previewFileCmd(selectedFile.Path)
+						return m, previewFileCmd(selectedFile.Path, 0)
 					}
 				}
 				return m, nil
-			case m.keyMap.Copy.Key: // Copy
+			case "copy": // Copy
 				sourcePane := &m.leftPane
 				destPane := &m.rightPane
 				if m.rightPane.active {
@@ -256,13 +474,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if len(files) == 0 && len(sourcePane.files) > 0 { // Nothing selected, use focused file
 					files = []file{sourcePane.files[sourcePane.cursor]}
 				}
-				if len(files) > 0 {
-					m.isMoving = false                              // It's a copy operation
+				if len(files) > 0 && destPane.listing == listingDirectory {
 					sourcePane.selected = make(map[string]struct{}) // Clear selection
-					return m, copyFilesCmd(files, destPane.path, false)
+					return m, copyFilesCmd(files, destPane.path, m.followSymlinks)
 				}
 				return m, nil
-			case m.keyMap.Move.Key: // Move
+			case "move": // Move
 				sourcePane := &m.leftPane
 				destPane := &m.rightPane
 				if m.rightPane.active {
@@ -273,30 +490,218 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if len(files) == 0 && len(sourcePane.files) > 0 { // Nothing selected, use focused file
 					files = []file{sourcePane.files[sourcePane.cursor]}
 				}
-				if len(files) > 0 {
-					m.isMoving = true                               // It's a move operation
+				if len(files) > 0 && destPane.listing == listingDirectory {
 					sourcePane.selected = make(map[string]struct{}) // Clear selection
-					return m, moveFilesCmd(files, destPane.path, false)
+					return m, moveFilesCmd(files, destPane.path)
+				}
+				return m, nil
+			case "cancel": // Cancel the search filling the active pane, or the running job
+				if activePane := m.activePane(); activePane.finder != nil {
+					activePane.stopFind()
+				} else if j := m.jobs.running(); j != nil {
+					m.jobs.cancel(j)
+				}
+				return m, nil
+			case "jobs":
+				m.showJobs = true
+				return m, nil
+			case "undo":
+				if e, ok := m.journal.popUndo(); ok {
+					return m, undoCmd(e)
+				}
+				return m, nil
+			case "redo":
+				if e, ok := m.journal.popRedo(); ok {
+					return m, redoCmd(e)
 				}
 				return m, nil
-			case m.keyMap.NewFolder.Key: // New Folder
-				m.isCreatingFolder = true
+			case "theme":
+				m.theme = (m.theme + 1) % len(themes)
+				applyTheme(themes[m.theme], m.colors, m.terminal)
+				return m, nil
+			case "follow_links":
+				m.followSymlinks = !m.followSymlinks
+				return m, nil
+			case "mkdir": // New Folder
+				if m.activePane().listing != listingDirectory {
+					return m, nil
+				}
+				m.openPrompt(promptMkdir, m.activePane().path)
+				return m, nil
+			case "goto":
+				m.openPrompt(promptGoTo, m.activePane().path)
+				return m, nil
+			case "sort_next", "sort_reverse", "sort_ignore_case", "sort_dirs_first", "toggle_hidden":
+				activePane := m.activePane()
+				switch action {
+				case "sort_next":
+					activePane.sort.by = (activePane.sort.by + 1) % sortKeyCount
+				case "sort_reverse":
+					activePane.sort.reverse = !activePane.sort.reverse
+				case "sort_ignore_case":
+					activePane.sort.ignoreCase = !activePane.sort.ignoreCase
+				case "sort_dirs_first":
+					activePane.sort.dirsFirst = !activePane.sort.dirsFirst
+				case "toggle_hidden":
+					activePane.showHidden = !activePane.showHidden
+				}
+				// Reread the folder, as the unsorted order and the hidden files
+				// are only known from there
+				focusPath := ""
+				if len(activePane.files) > 0 {
+					focusPath = activePane.files[activePane.cursor].Path
+				}
+				return m, activePane.loadDirectoryCmd(focusPath)
+			case "layout_next":
+				activePane := m.activePane()
+				activePane.layout.mode = (activePane.layout.mode + 1) % listModeCount
+				activePane.scrollToCursor()
 				return m, nil
-			case m.keyMap.Delete.Key: // Delete
+			case "find", "grep":
+				activePane := m.activePane()
+				switch {
+				case activePane.listing == listingTrash:
+				case action == "find":
+					m.findDialog = newFindDialog(activePane.path)
+				default:
+					m.grepDialog = newGrepDialog(activePane.path)
+				}
+				return m, nil
+			case "open":
+				// Lines found by a content search open in the preview; the rest is
+				// up to the pane
+				if activePane := m.activePane(); activePane.cursor < len(activePane.files) {
+					if f := activePane.files[activePane.cursor]; f.Match != nil {
+						return m, m.openMatch(f)
+					}
+				}
+			case "command_line":
+				m.openPrompt(promptCommand, m.activePane().path)
+				return m, nil
+			case "rename":
+				activePane := m.activePane()
+				if activePane.listing != listingDirectory || len(activePane.files) == 0 {
+					return m, nil
+				}
+				f := activePane.files[activePane.cursor]
+				if f.Name == ".." {
+					return m, nil
+				}
+				input := newTextInput(f.Name).withHistory(m.history(promptRename))
+				// Preselect the base name so typing replaces it but keeps the extension
+				base, _ := splitExt(f)
+				input.selectRange(0, len([]rune(base)))
+				activePane.renameInput = &input
+				activePane.renamePath = f.Path
+				m.renameErr = nil
+				return m, nil
+			case "filter":
+				activePane := m.activePane()
+				input := newTextInput(activePane.filter.query).withHistory(m.history(promptFilter))
+				activePane.filterInput = &input
+				return m, nil
+			case "batch_rename":
+				activePane := m.activePane()
+				if activePane.listing != listingDirectory {
+					return m, nil
+				}
+				files := slices.DeleteFunc(getFilesFromSelected(*activePane), func(f file) bool { return f.Name == ".." })
+				if len(files) == 0 && len(activePane.files) > 0 {
+					if f := activePane.files[activePane.cursor]; f.Name != ".." {
+						files = []file{f}
+					}
+				}
+				if len(files) > 0 {
+					m.batchRename = newBatchRename(*activePane, files)
+				}
+				return m, nil
+			case "edit", "pager":
+				activePane := m.activePane()
+				if len(activePane.files) == 0 {
+					return m, nil
+				}
+				f := activePane.files[activePane.cursor]
+				if f.IsDir {
+					return m, nil
+				}
+				if action == "edit" {
+					return m, editFileCmd(activePane.id, f.Path)
+				}
+				return m, pageFileCmd(activePane.id, f.Path)
+			case "edit_names":
+				activePane := m.activePane()
+				if activePane.listing != listingDirectory {
+					return m, nil
+				}
+				files := getFilesFromSelected(*activePane)
+				if len(files) == 0 {
+					// Nothing selected, edit the whole folder
+					for _, f := range activePane.files {
+						if f.Name != ".." {
+							files = append(files, f)
+						}
+					}
+				}
+				if len(files) > 0 {
+					return m, editNamesCmd(*activePane, files)
+				}
+				return m, nil
+			case "delete", "delete_permanently": // Delete
 				activePane := &m.leftPane
 				if m.rightPane.active {
 					activePane = &m.rightPane
 				}
 				files := getFilesFromSelected(*activePane)
 				if len(files) == 0 && len(activePane.files) > 0 { // Nothing selected, use focused file
-					files = []file{activePane.files[activePane.cursor]}
+					if f := activePane.files[activePane.cursor]; f.Name != ".." || activePane.listing == listingTrash {
+						files = []file{f}
+					}
 				}
 				if len(files) > 0 {
 					m.isDeleting = true
 					m.filesToDelete = files
+					// Items already in the trash can only be deleted for good
+					m.deletePermanently = action == "delete_permanently" || activePane.listing == listingTrash
+					if m.deletePermanently && !m.confirm.Delete || !m.deletePermanently && !m.confirm.Trash {
+						return m, m.deleteFiles()
+					}
+				}
+				return m, nil
+			case "trash": // Toggle the trash view in the active pane
+				activePane := &m.leftPane
+				if m.rightPane.active {
+					activePane = &m.rightPane
+				}
+				if activePane.listing == listingTrash {
+					activePane.listing = listingDirectory
+				} else {
+					activePane.listing = listingTrash
+				}
+				activePane.stopFind()
+				activePane.clearFilter()
+				activePane.cursor = 0
+				activePane.viewportY = 0
+				activePane.selected = make(map[string]struct{})
+				return m, activePane.loadDirectoryCmd("")
+			case "restore": // Restore from the trash view
+				activePane := &m.leftPane
+				if m.rightPane.active {
+					activePane = &m.rightPane
+				}
+				if activePane.listing != listingTrash {
+					return m, nil
+				}
+				files := getFilesFromSelected(*activePane)
+				if len(files) == 0 && len(activePane.files) > 0 {
+					files = []file{activePane.files[activePane.cursor]}
+				}
+				entries := activePane.trashEntriesFor(files)
+				activePane.selected = make(map[string]struct{})
+				if len(entries) > 0 {
+					return m, restoreTrashCmd(entries)
 				}
 				return m, nil
-			case m.keyMap.CopyPath.Key:
+			case "copy_path":
 				activePane := &m.leftPane
 				if m.rightPane.active {
 					activePane = &m.rightPane
@@ -313,6 +718,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, copyToClipboardCmd(strings.Join(paths, "\n"))
 				}
 				return m, nil
+			default:
+				if c, ok := m.customActions[action]; ok {
+					return m, m.runCustomAction(c)
+				}
 			}
 		}
 	}
@@ -321,16 +730,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case directoryLoadedMsg:
 		if msg.paneID == m.leftPane.id {
-			m.leftPane.files = msg.files
+			m.leftPane.setFiles(msg.files)
+			m.leftPane.hidden = msg.hidden
 			m.leftPane.err = msg.err
+			m.leftPane.trash = msg.trash
 			if msg.focusPath != "" {
 				for i, f := range m.leftPane.files {
 					if f.Path == msg.focusPath {
 						m.leftPane.cursor = i
-						// Adjust viewport to make cursor visible
-						if m.leftPane.cursor >= m.leftPane.viewportY+m.leftPane.height-2 {
-							m.leftPane.viewportY = m.leftPane.cursor - m.leftPane.height + 3
-						}
+						m.leftPane.scrollToCursor()
 						break
 					}
 				}
@@ -338,16 +746,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Ensure cursor is within bounds after directory reload
 			ensureCursorInBounds(&m.leftPane)
 		} else if msg.paneID == m.rightPane.id {
-			m.rightPane.files = msg.files
+			m.rightPane.setFiles(msg.files)
+			m.rightPane.hidden = msg.hidden
 			m.rightPane.err = msg.err
+			m.rightPane.trash = msg.trash
 			if msg.focusPath != "" {
 				for i, f := range m.rightPane.files {
 					if f.Path == msg.focusPath {
 						m.rightPane.cursor = i
-						// Adjust viewport to make cursor visible
-						if m.rightPane.cursor >= m.rightPane.viewportY+m.rightPane.height-2 {
-							m.rightPane.viewportY = m.rightPane.cursor - m.rightPane.height + 3
-						}
+						m.rightPane.scrollToCursor()
 						break
 					}
 				}
@@ -356,6 +763,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			ensureCursorInBounds(&m.rightPane)
 		}
 		return m, nil
+	case findResultsMsg:
+		p := &m.leftPane
+		if msg.paneID == m.rightPane.id {
+			p = &m.rightPane
+		}
+		if p.finder == nil || p.finder.id != msg.id {
+			return m, nil // The search was cancelled
+		}
+		p.setFiles(append(p.unfiltered, msg.files...))
+		return m, p.finder.listenCmd()
+	case findDoneMsg:
+		p := &m.leftPane
+		if msg.paneID == m.rightPane.id {
+			p = &m.rightPane
+		}
+		if p.finder == nil || p.finder.id != msg.id {
+			return m, nil
+		}
+		p.finder = nil
+		if msg.err != nil {
+			m.err = fmt.Errorf("find: %w", msg.err)
+		}
+		// Catch up with what changed while the search ran
+		focusPath := ""
+		if p.cursor < len(p.files) {
+			focusPath = p.files[p.cursor].Path
+		}
+		return m, p.loadDirectoryCmd(focusPath)
 	case tea.WindowSizeMsg:
 		// Handle window resizing
 		// Height includes:
@@ -380,6 +815,56 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.leftPane.width = paneWidth
 		m.rightPane.width = paneWidth
 		return m, nil
+	case externalExitedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		// The file may have been changed or saved under another name
+		p, other := &m.leftPane, &m.rightPane
+		if msg.paneID == m.rightPane.id {
+			p, other = other, p
+		}
+		return m, tea.Batch(p.loadDirectoryCmd(msg.focusPath), other.loadDirectoryCmd(""))
+	case commandOutputMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		// The output is shown like a file preview
+		activePane := m.activePane()
+		m.isPreviewing = true
+		m.previewFilePath = ""
+		m.previewContent = msg.output
+		if msg.output == "" {
+			m.previewContent = "--- No output ---"
+		}
+		m.previewWidth = activePane.width
+		m.previewHeight = activePane.height
+		m.previewScrollY = 0
+		return m, tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	case actionDoneMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		if msg.show {
+			activePane := m.activePane()
+			m.isPreviewing = true
+			m.previewFilePath = ""
+			m.previewContent = msg.output
+			if m.previewContent == "" {
+				m.previewContent = "--- No output ---"
+			}
+			m.previewWidth = activePane.width
+			m.previewHeight = activePane.height
+			m.previewScrollY = 0
+		}
+		if !msg.refresh {
+			return m, nil
+		}
+		p, other := &m.leftPane, &m.rightPane
+		if msg.paneID == m.rightPane.id {
+			p, other = other, p
+		}
+		return m, tea.Batch(p.loadDirectoryCmd(msg.focusPath), other.loadDirectoryCmd(""))
 	case fileOpenedMsg:
 		if msg.err != nil {
 			m.err = msg.err
@@ -389,6 +874,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			m.err = msg.err
 		} else {
+			m.journal.record(journalEntry{Kind: journalMkdir, Items: []journalItem{{To: msg.folderPath}}})
 			// Reload directory in active pane and focus on the newly created folder
 			if m.leftPane.active {
 				return m, m.leftPane.loadDirectoryCmd(msg.folderPath)
@@ -397,103 +883,183 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		return m, nil
-	case fileDeletedMsg:
+	case namesEditedMsg:
 		if msg.err != nil {
 			m.err = msg.err
-		} else {
-			// Adjust cursor if it's out of bounds after deletion
-			activePane := &m.leftPane
-			if m.rightPane.active {
-				activePane = &m.rightPane
-			}
-			if activePane.cursor >= len(activePane.files)-1 && activePane.cursor > 0 {
-				activePane.cursor--
-			}
-
-			// Reload directory in active pane
-			if m.leftPane.active {
-				return m, m.leftPane.loadDirectoryCmd("")
-			} else {
-				return m, m.rightPane.loadDirectoryCmd("")
-			}
+			return m, nil
+		}
+		if len(msg.edits.renames) > 0 || len(msg.edits.trash) > 0 {
+			m.nameEdits = &msg.edits
 		}
 		return m, nil
-	case fileConflictMsg:
-		m.isConfirmingOverwrite = true
-		m.overwriteConflicts = msg.Conflicts
-		return m, nil
-	case fileOperationMsg: // For copy/move operations
+	case namesAppliedMsg:
 		if msg.err != nil {
 			m.err = msg.err
-		} else {
-			// Reload both source and destination panes
-			cmds := []tea.Cmd{m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd("")}
-			return m, tea.Batch(cmds...)
 		}
+		// Recorded separately so undo renames back before restoring from the trash
+		m.journal.record(journalEntry{Kind: journalTrash, Items: msg.trashed})
+		m.journal.record(journalEntry{Kind: journalRename, Items: msg.renamed})
+		p := &m.leftPane
+		if msg.paneID == m.rightPane.id {
+			p = &m.rightPane
+		}
+		p.selected = make(map[string]struct{})
+		return m, tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	case batchRenamedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		m.journal.record(journalEntry{Kind: journalRename, Items: msg.done})
+		p := &m.leftPane
+		if msg.paneID == m.rightPane.id {
+			p = &m.rightPane
+		}
+		p.selected = make(map[string]struct{})
+		return m, tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	case fileRenamedMsg:
+		p := &m.leftPane
+		if msg.paneID == m.rightPane.id {
+			p = &m.rightPane
+		}
+		if msg.err != nil {
+			m.renameErr = msg.err // Keep the editor open so the name can be fixed
+			return m, nil
+		}
+		p.renameInput = nil
+		m.renameErr = nil
+		m.journal.record(journalEntry{Kind: journalRename, Items: []journalItem{{From: msg.from, To: msg.to}}})
+		if _, ok := p.selected[msg.from]; ok {
+			delete(p.selected, msg.from)
+			p.selected[msg.to] = struct{}{}
+		}
+		// The other pane may be showing the same folder
+		other := &m.rightPane
+		if p == &m.rightPane {
+			other = &m.leftPane
+		}
+		return m, tea.Batch(p.loadDirectoryCmd(msg.to), other.loadDirectoryCmd(""))
+	case journalAppliedMsg:
+		m.journal.applied(msg)
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	case trashUpdatedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, tea.Batch(m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd(""))
+	case jobPreparedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		m.queueJob(msg.job)
 		return m, nil
+	case jobProgressMsg:
+		if j := m.jobs.get(msg.id); j != nil {
+			j.progress = msg.Progress
+		}
+		return m, m.jobs.listenCmd()
+	case jobFinishedMsg:
+		j := m.jobs.finish(msg.id, msg.err, msg.failed)
+		if j != nil && j.err != nil {
+			m.err = fmt.Errorf("job %d: %w", j.id, j.err)
+		}
+		if j != nil {
+			if kind, ok := j.kind.journalKind(); ok {
+				m.journal.record(journalEntry{Kind: kind, Items: msg.done})
+			}
+		}
+		// Reload both panes, even a failed or cancelled job may have
+		// changed part of its files.
+		cmds := []tea.Cmd{m.jobs.listenCmd(), m.leftPane.loadDirectoryCmd(""), m.rightPane.loadDirectoryCmd("")}
+		return m, tea.Batch(cmds...)
 	case previewReadyMsg:
 		m.previewContent = msg.Content
 		if msg.Err != nil {
 			m.err = msg.Err
 		}
+		if m.previewMatch != nil {
+			// Scroll the line found into view, a third down the preview
+			m.previewMatchLine = m.previewMatch.Line - 1 + msg.headerLines
+			start, _ := wrappedLineSpan(m.previewContent, m.previewWidth-6, m.previewMatchLine)
+			m.previewScrollY = max(0, start-(m.previewHeight-4)/3)
+		}
 		return m, nil
 	default:
 		// logDebug("Unknown message: %T", msg)
 	}
 
 	// Delegate updates to active pane only if not in an operation mode
-	if !m.isCreatingFolder && !m.isDeleting && !m.isConfirmingOverwrite && !m.isPreviewing {
+	if m.palette == nil && m.prompt == nil && m.activePane().renameInput == nil && m.activePane().filterInput == nil && m.findDialog == nil && m.grepDialog == nil && m.batchRename == nil && m.nameEdits == nil && m.pendingAction == nil && !m.isDeleting && !m.isConfirmingOverwrite && !m.showJobs && !m.isPreviewing {
 		if m.leftPane.active {
-			m.leftPane, cmd = m.leftPane.update(msg)
+			m.leftPane, cmd = m.leftPane.update(msg, action)
 		} else {
-			m.rightPane, cmd = m.rightPane.update(msg)
+			m.rightPane, cmd = m.rightPane.update(msg, action)
 		}
 	}
 	return m, cmd
 }
 
-// update handles messages for a pane.
-func (p pane) update(msg tea.Msg) (pane, tea.Cmd) {
+// update handles messages for a pane. action is the command bound to the
+// key pressed, if any.
+func (p pane) update(msg tea.Msg, action string) (pane, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "home":
+		switch action {
+		case "top":
 			p.cursor = 0
-		case "end":
+		case "bottom":
 			if len(p.files) > 0 {
 				p.cursor = len(p.files) - 1
 			} else {
 				p.cursor = 0
 			}
-		case "up":
+		case "cursor_up":
 			p.searchQuery = "" // Clear search on navigation
 			if p.cursor > 0 {
 				p.cursor--
 			}
-		case "down":
+		case "cursor_down":
 			p.searchQuery = "" // Clear search on navigation
 			if p.cursor < len(p.files)-1 {
 				p.cursor++
 			}
-		case "pgup":
+		case "cursor_left", "cursor_right":
+			if p.briefColumnCount() > 1 && len(p.files) > 0 {
+				p.searchQuery = ""
+				if action == "cursor_left" {
+					p.cursor = max(0, p.cursor-p.rows())
+				} else {
+					p.cursor = min(len(p.files)-1, p.cursor+p.rows())
+				}
+			}
+		case "page_up":
 			p.searchQuery = "" // Clear search on navigation
-			p.cursor -= p.height
+			p.cursor -= p.pageSize()
 			if p.cursor < 0 {
 				p.cursor = 0
 			}
-		case "pgdown":
+		case "page_down":
 			p.searchQuery = "" // Clear search on navigation
 			if len(p.files) > 0 {
-				p.cursor += p.height
+				p.cursor += p.pageSize()
 				if p.cursor >= len(p.files) {
 					p.cursor = len(p.files) - 1
 				}
 			}
-		case "enter":
+		case "open":
 			p.searchQuery = "" // Clear search on navigation
+			if p.listing == listingFind && len(p.files) > 0 {
+				return p.leaveFind(p.files[p.cursor])
+			}
 			if len(p.files) > 0 {
 				selectedFile := p.files[p.cursor]
+				if selectedFile.IsDir && p.listing == listingTrash {
+					break // Trashed folders are restored, not browsed
+				}
 				if selectedFile.IsDir {
+					p.clearFilter() // Filters are for the folder they were typed in
 					// Check if it's the parent directory entry ".."
 					if selectedFile.Name == ".." {
 						currentPath := p.path
@@ -507,12 +1073,32 @@ func (p pane) update(msg tea.Msg) (pane, tea.Cmd) {
 					p.viewportY = 0 // Reset viewport when entering a new directory
 					return p, p.loadDirectoryCmd("")
 				} else {
-					return p, openFileCmd(selectedFile.Path)
+					return p, p.openCmd(selectedFile)
 				}
 			}
-		case "esc":
+		case "clear_search":
 			p.searchQuery = "" // Clear search explicitly
-		case "insert", "alt+i":
+			p.clearFilter()
+		case "select_all":
+			var shown []string
+			allSelected := true
+			for _, f := range p.files {
+				if f.Name == ".." {
+					continue
+				}
+				shown = append(shown, f.Path)
+				if _, ok := p.selected[f.Path]; !ok {
+					allSelected = false
+				}
+			}
+			for _, path := range shown {
+				if allSelected {
+					delete(p.selected, path)
+				} else {
+					p.selected[path] = struct{}{}
+				}
+			}
+		case "select":
 			if len(p.files) > 0 {
 				filePath := p.files[p.cursor].Path
 				if _, ok := p.selected[filePath]; ok {
@@ -525,29 +1111,113 @@ func (p pane) update(msg tea.Msg) (pane, tea.Cmd) {
 					p.cursor++
 				}
 			}
+		case "search_next":
+			p.nextMatch(1)
+		case "search_prev":
+			p.nextMatch(-1)
 		default:
 			// Handle active search
 			if len(msg.String()) == 1 { // Only process single character inputs
 				p.searchQuery += msg.String()
-				lowerSearchQuery := strings.ToLower(p.searchQuery)
-
-				for i, f := range p.files {
-					if strings.HasPrefix(strings.ToLower(f.Name), lowerSearchQuery) {
-						p.cursor = i
-						break
-					}
-				}
+				p.search()
+			} else if msg.Type == tea.KeyBackspace && p.searchQuery != "" {
+				p.searchQuery = p.searchQuery[:len(p.searchQuery)-1]
+				p.search()
 			}
 		}
 	}
 
-	// Ensure viewport is within bounds
-	if p.cursor < p.viewportY {
-		p.viewportY = p.cursor
+	p.scrollToCursor()
+
+	return p, nil
+}
+
+// submitPrompt acts on the answer to the status bar prompt. The prompt stays
+// open with an error if the answer can't be used.
+func (m model) submitPrompt() (tea.Model, tea.Cmd) {
+	if m.prompt.kind == promptCommand {
+		return m.runCommandLine(false)
+	}
+	activePane := m.activePane()
+	value := strings.TrimSpace(m.prompt.input.Value())
+	if value == "" {
+		m.prompt = nil
+		m.promptErr = nil
+		return m, nil
 	}
-	if p.cursor >= p.viewportY+p.height-2 {
-		p.viewportY = p.cursor - p.height + 3
+	path := expandHome(value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(activePane.path, path)
 	}
+	path = filepath.Clean(path)
 
-	return p, nil
+	switch m.prompt.kind {
+	case promptMkdir:
+		m.prompt.input.commit()
+		m.prompt = nil
+		m.promptErr = nil
+		return m, createFolderCmd(path)
+	case promptGoTo:
+		info, err := os.Stat(path)
+		if err != nil {
+			m.promptErr = err
+			return m, nil
+		}
+		m.prompt.input.commit()
+		m.prompt = nil
+		m.promptErr = nil
+		focusPath := ""
+		if !info.IsDir() {
+			// Going to a file shows it in its folder
+			focusPath = path
+			path = filepath.Dir(path)
+		}
+		activePane.path = path
+		activePane.listing = listingDirectory
+		activePane.searchQuery = ""
+		activePane.stopFind()
+		activePane.clearFilter()
+		activePane.cursor = 0
+		activePane.viewportY = 0
+		activePane.selected = make(map[string]struct{})
+		return m, activePane.loadDirectoryCmd(focusPath)
+	}
+	return m, nil
+}
+
+// keyMode returns the mode whose bindings apply to the next key press.
+func (m *model) keyMode() keyMode {
+	switch {
+	case m.palette != nil || m.prompt != nil || m.activePane().renameInput != nil || m.activePane().filterInput != nil || m.findDialog != nil || m.grepDialog != nil || m.batchRename != nil ||
+		m.nameEdits != nil || m.pendingAction != nil || m.isDeleting || m.isConfirmingOverwrite:
+		return modePrompt
+	case m.showJobs:
+		return modeJobs
+	case m.isPreviewing:
+		return modePreview
+	}
+	return modePane
+}
+
+// resolveKey returns the command bound to the key press in the current mode.
+// pending reports that the key continues a chord, which waits for more keys.
+// A chord that turns out not to be bound is dropped, and the key that broke
+// it is looked up on its own.
+func (m *model) resolveKey(msg tea.KeyMsg) (action string, pending bool) {
+	mode := m.keyMode()
+	keys := []string{keyName(msg)}
+	if mode == m.chordMode {
+		keys = append(slices.Clone(m.chord), keys...)
+	}
+	action, prefix := m.keyMap.lookup(mode, keys)
+	if action == "" && !prefix && len(keys) > 1 {
+		keys = keys[len(keys)-1:]
+		action, prefix = m.keyMap.lookup(mode, keys)
+	}
+	m.chord, m.chordMode = nil, mode
+	if prefix {
+		m.chord = keys
+		return "", true
+	}
+	return action, false
 }
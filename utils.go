@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -14,3 +16,87 @@ func calculateWrappedLines(content string, width int) []string {
 	wrappedContent := lipgloss.NewStyle().Width(width).Render(content)
 	return strings.Split(wrappedContent, "\n")
 }
+
+// wrappedLineSpan returns the range of lines that line of content, counted
+// from 0, takes once wrapped by calculateWrappedLines.
+func wrappedLineSpan(content string, width, line int) (start, end int) {
+	for i, l := range strings.Split(content, "\n") {
+		n := len(calculateWrappedLines(l, width))
+		if i == line {
+			return start, start + n
+		}
+		start += n
+	}
+	return start, start
+}
+
+// truncateMiddle shortens s to width cells by replacing its middle with an
+// ellipsis, which keeps both the start and the extension of file names visible.
+func truncateMiddle(s string, width int) string {
+	head, tail, cut := middleCut(s, width)
+	if !cut {
+		return s
+	}
+	r := []rune(s)
+	if width < 2 {
+		return string(r[:head])
+	}
+	return string(r[:head]) + "…" + string(r[len(r)-tail:])
+}
+
+// middleCut returns the number of runes truncateMiddle keeps before and after
+// the ellipsis to fit s into width cells, or cut unset when s fits as it is.
+// Wide runes count for two cells.
+func middleCut(s string, width int) (head, tail int, cut bool) {
+	if lipgloss.Width(s) <= width {
+		return 0, 0, false
+	}
+	r := []rune(s)
+	// take returns how many runes fit in cells, walking r in steps of step
+	// from from
+	take := func(cells, from, step int) int {
+		n := 0
+		for i := from; i >= 0 && i < len(r); i += step {
+			if cells -= lipgloss.Width(string(r[i])); cells < 0 {
+				break
+			}
+			n++
+		}
+		return n
+	}
+	if width < 2 {
+		return take(max(0, width), 0, 1), 0, true
+	}
+	keep := width - 1
+	head = take((keep+1)/2, 0, 1)
+	tail = take(keep/2, len(r)-1, -1)
+	return head, tail, true
+}
+
+// formatBytes renders a byte count in human-readable binary units (e.g. "1.5 MiB").
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration renders a duration as m:ss, or h:mm:ss for long durations.
+func formatDuration(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// clamp limits v to the range [lo, hi].
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -45,6 +46,13 @@ func (m model) View() string {
 		}
 
 		visibleLines := contentLines[start:end]
+		if m.previewMatch != nil {
+			// Highlight what the content search found on its line
+			from, to := wrappedLineSpan(m.previewContent, innerWidth, m.previewMatchLine)
+			for i := max(from, start); i < min(to, end); i++ {
+				visibleLines[i-start] = highlightMatches(visibleLines[i-start], m.previewMatch.re)
+			}
+		}
 
 		previewView := previewStyle.Width(m.previewWidth).Height(m.previewHeight).Render(strings.Join(visibleLines, "\n"))
 		if m.leftPane.active {
@@ -57,30 +65,113 @@ func (m model) View() string {
 
 	leftView := paneView(m.leftPane)
 	rightView := paneView(m.rightPane)
+	if m.findDialog != nil || m.grepDialog != nil {
+		// The search dialogs take the place of the pane they search from
+		dialogView := m.findDialogView
+		if m.grepDialog != nil {
+			dialogView = m.grepDialogView
+		}
+		if m.leftPane.active {
+			leftView = dialogView(m.leftPane.width, m.leftPane.height)
+		} else {
+			rightView = dialogView(m.rightPane.width, m.rightPane.height)
+		}
+	}
+	if m.showJobs {
+		// The job list takes the place of the inactive pane
+		if m.leftPane.active {
+			rightView = m.jobsView(m.rightPane.width, m.rightPane.height)
+		} else {
+			leftView = m.jobsView(m.leftPane.width, m.leftPane.height)
+		}
+	}
+
+	panes := lipgloss.JoinHorizontal(lipgloss.Top, leftView, rightView)
+	if m.batchRename != nil {
+		// The multi-rename dialog spans both panes to fit its two columns
+		panes = m.batchRenameView(m.leftPane.width+m.rightPane.width+2, m.leftPane.height)
+	}
+	if m.palette != nil {
+		panes = m.paletteView(m.leftPane.width+m.rightPane.width+2, m.leftPane.height)
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Left,
-		lipgloss.JoinHorizontal(lipgloss.Top, leftView, rightView),
+		panes,
 		m.statusBarView(),
 		m.hintsView(),
 	)
 }
 
 func (m model) statusBarView() string {
-	if m.isCreatingFolder {
-		return inputPromptStyle.Render("Create folder: " + m.folderNameInput)
+	if m.prompt != nil {
+		if m.promptErr != nil {
+			return confirmPromptStyle.Render(m.prompt.label() + m.promptErr.Error())
+		}
+		return inputPromptStyle.Render(m.prompt.label() + m.prompt.input.View())
+	}
+
+	if m.activePane().renameInput != nil {
+		if m.renameErr != nil {
+			return confirmPromptStyle.Render("Rename: " + m.renameErr.Error())
+		}
+		return inputPromptStyle.Render(fmt.Sprintf("Rename: %s to confirm, %s to cancel", m.keyMap.keyFor("prompt_confirm"), m.keyMap.keyFor("prompt_cancel")))
+	}
+
+	if p := m.activePane(); p.filterInput != nil {
+		status := fmt.Sprintf("Filter: %s to keep, %s to clear, %s to match as %s", m.keyMap.keyFor("prompt_confirm"), m.keyMap.keyFor("prompt_cancel"), m.keyMap.keyFor("prompt_option"), (p.filter.kind+1)%filterKindCount)
+		return inputPromptStyle.Render(status)
+	}
+
+	if m.nameEdits != nil {
+		var parts []string
+		if n := len(m.nameEdits.renames); n == 1 {
+			parts = append(parts, "rename 1 item")
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("rename %d items", n))
+		}
+		if n := len(m.nameEdits.trash); n == 1 {
+			parts = append(parts, "move 1 item to trash")
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("move %d items to trash", n))
+		}
+		summary := strings.Join(parts, " and ")
+		return confirmPromptStyle.Render(strings.ToUpper(summary[:1]) + summary[1:] + "? (y/n)")
+	}
+
+	if m.pendingAction != nil {
+		what := "1 item"
+		if n := m.pendingAction.count; n > 1 {
+			what = fmt.Sprintf("%d items", n)
+		}
+		return confirmPromptStyle.Render(fmt.Sprintf("Run %s on %s? (y/n)", m.pendingAction.label, what))
 	}
 
 	if m.isDeleting {
+		what := fmt.Sprintf("%d items", len(m.filesToDelete))
 		if len(m.filesToDelete) == 1 {
-			return confirmPromptStyle.Render(fmt.Sprintf("Delete %s? (y/n)", m.filesToDelete[0].Name))
+			what = m.filesToDelete[0].Name
+		}
+		if m.deletePermanently {
+			return confirmPromptStyle.Render(fmt.Sprintf("Permanently delete %s? (y/n)", what))
 		}
-		return confirmPromptStyle.Render(fmt.Sprintf("Delete %d items? (y/n)", len(m.filesToDelete)))
+		return confirmPromptStyle.Render(fmt.Sprintf("Move %s to trash? (y/n)", what))
 	}
 
 	if m.isConfirmingOverwrite {
-		if len(m.overwriteConflicts) > 0 {
-			return overwritePromptStyle.Render(fmt.Sprintf("Overwrite %s? (y/n/A/s)", m.overwriteConflicts[0].Source.Name))
-		}
+		j := m.conflictJobs[0]
+		return overwritePromptStyle.Render(fmt.Sprintf("%s: overwrite %s? (y/n/A/s)", j.kind, j.conflicts[0].Source.Name))
+	}
+
+	if len(m.chord) > 0 {
+		return inputPromptStyle.Render(strings.Join(m.chord, " ") + " …")
+	}
+
+	if m.err != nil {
+		return errorBarStyle.Render("Error: " + m.err.Error())
+	}
+
+	if j := m.jobs.running(); j != nil {
+		return m.progressView(j)
 	}
 
 	activePane := m.leftPane
@@ -90,11 +181,16 @@ func (m model) statusBarView() string {
 
 	var search string
 	if activePane.searchQuery != "" {
-		search = "Search: " + activePane.searchQuery
+		search = fmt.Sprintf("Search: %s (%d found)", activePane.searchQuery, activePane.searchMatchCount())
+	}
+
+	var modes string
+	if m.followSymlinks {
+		modes = statusBarActive.Render("Follow links")
 	}
 
 	if len(activePane.files) == 0 || activePane.cursor >= len(activePane.files) {
-		return statusBar.Render(search)
+		return lipgloss.JoinHorizontal(lipgloss.Left, modes, statusBar.Render(search))
 	}
 
 	f := activePane.files[activePane.cursor]
@@ -103,47 +199,368 @@ func (m model) statusBarView() string {
 	// Calculate available space for the status, leaving room for the search query
 	w := lipgloss.Width
 	statusWidth := w(status)
-	searchWidth := w(search)
+	searchWidth := w(search) + w(modes)
 	availableWidth := m.leftPane.width + m.rightPane.width + 2 - searchWidth
 	if availableWidth < statusWidth {
-		status = status[:availableWidth]
+		status = truncateMiddle(status, max(0, availableWidth))
 	}
 
 	return lipgloss.JoinHorizontal(lipgloss.Left,
+		modes,
 		statusBarActive.Render(search),
 		statusBar.Render(status),
 	)
 }
 
-func paneView(p pane) string {
-	var s strings.Builder
-	s.WriteString(p.path + "\n")
+// progressView renders the progress of the running job.
+func (m model) progressView(j *job) string {
+	p := j.progress
+	const barWidth = 20
+	filled := int(p.fraction() * barWidth)
+	if filled > barWidth {
+		filled = barWidth
+	}
+	bar := progressFillStyle.Render(strings.Repeat("█", filled)) + strings.Repeat("░", barWidth-filled)
+
+	status := fmt.Sprintf("%s %s %s %3.0f%%", j.kind.verb(), p.CurrentFile, bar, p.fraction()*100)
+	if p.BytesTotal > 0 {
+		status += fmt.Sprintf(" | %s / %s | %s/s | ETA %s",
+			formatBytes(p.BytesDone), formatBytes(p.BytesTotal),
+			formatBytes(int64(p.throughput())), formatDuration(p.eta()))
+	} else {
+		status += fmt.Sprintf(" | %d / %d items", p.ItemsDone, p.ItemsTotal)
+	}
+	if queued := m.jobs.count(jobQueued); queued > 0 {
+		status += fmt.Sprintf(" | %d queued", queued)
+	}
+	status += fmt.Sprintf(" | %s to cancel", m.keyMap.keyFor("cancel"))
+	return progressStyle.MaxWidth(m.leftPane.width + m.rightPane.width + 4).Render(status)
+}
 
-	for i := p.viewportY; i < len(p.files) && i < p.viewportY+p.height-2; i++ {
-		f := p.files[i]
-		line := " " + f.Name
-		if f.IsDir {
-			line = " " + dirStyle.Render(f.Name)
+// keyHelp lists the keys bound to commands with what they do, such as
+// "r:retry x:cancel", from pairs of a command and its description. Commands
+// without a key are left out.
+func (m model) keyHelp(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if key := m.keyMap.keyFor(pairs[i]); key != "" {
+			parts = append(parts, key+":"+pairs[i+1])
 		}
+	}
+	return strings.Join(parts, " ")
+}
 
-		_, isSelected := p.selected[f.Path]
+// jobsView renders the job list panel.
+func (m model) jobsView(width, height int) string {
+	var s strings.Builder
+	s.WriteString("Jobs  " + m.keyHelp("jobs_pause", "pause/resume", "jobs_retry", "retry", "jobs_cancel", "cancel", "jobs_clear", "clear finished", "jobs_close", "close") + "\n")
+	if len(m.jobs.jobs) == 0 {
+		s.WriteString(" No jobs\n")
+	}
 
-		if i == p.cursor {
+	// The pane may have shrunk since the list last scrolled
+	rows := m.jobRows()
+	from := clamp(m.jobScrollY, max(0, m.jobCursor-rows+1), m.jobCursor)
+	for i := from; i < len(m.jobs.jobs) && i < from+rows; i++ {
+		j := m.jobs.jobs[i]
+		line := fmt.Sprintf(" #%d %-9s %s", j.id, j.state, j.title())
+		switch {
+		case j.state == jobRunning || (j.state == jobPaused && j.ctl != nil):
+			line += fmt.Sprintf(" (%.0f%%)", j.progress.fraction()*100)
+		case j.state == jobFailed:
+			line += ": " + j.err.Error()
+		}
+		if i == m.jobCursor {
 			s.WriteString(cursorStyle.Render(line))
-		} else if isSelected {
-			s.WriteString(selectionStyle.Render(line))
 		} else {
 			s.WriteString(line)
 		}
 		s.WriteString("\n")
 	}
 
+	return activeStyle.Width(width).Height(height).Render(s.String())
+}
+
+func (m model) findDialogView(width, height int) string {
+	d := m.findDialog
+	var s strings.Builder
+	s.WriteString(truncateMiddle("Find in "+d.root, width) + "\n\n")
+	labels := [findFieldCount]string{"Name:     ", "Size:     ", "Modified: "}
+	for i, label := range labels {
+		if i == d.focus {
+			s.WriteString(" " + label + d.inputs[i].View() + "\n")
+		} else {
+			s.WriteString(" " + label + d.inputs[i].Value() + "\n")
+		}
+	}
+	nameMode := "glob"
+	if d.regex {
+		nameMode = "regex"
+	}
+	s.WriteString(fmt.Sprintf("\n Name: %s  Type: %s\n", nameMode, d.kind))
+	if d.err != nil {
+		s.WriteString(" " + errorStyle.Render(d.err.Error()) + "\n")
+	} else {
+		s.WriteString("\n")
+	}
+	help := []string{
+		"",
+		m.keyHelp("prompt_next_field", "next field", "prompt_regex", "glob/regex"),
+		m.keyHelp("prompt_option", "type", "prompt_confirm", "find", "prompt_cancel", "cancel"),
+		"",
+		"Size: 10K.. ..1M 10K..1.5M",
+		"Modified: 7d.. ..2024-01-31 2h..1d",
+	}
+	for _, line := range help {
+		s.WriteString(" " + trashInfoStyle.Render(line) + "\n")
+	}
+
+	return activeStyle.Width(width).Height(height).Render(s.String())
+}
+
+func (m model) grepDialogView(width, height int) string {
+	d := m.grepDialog
+	var s strings.Builder
+	s.WriteString(truncateMiddle("Search text in "+d.root, width) + "\n\n")
+	labels := [grepFieldCount]string{"Text:    ", "Include: ", "Exclude: "}
+	for i, label := range labels {
+		if i == d.focus {
+			s.WriteString(" " + label + d.inputs[i].View() + "\n")
+		} else {
+			s.WriteString(" " + label + d.inputs[i].Value() + "\n")
+		}
+	}
+	mode, letterCase := "text", "match case"
+	if d.regex {
+		mode = "regex"
+	}
+	if d.ignoreCase {
+		letterCase = "any case"
+	}
+	s.WriteString(fmt.Sprintf("\n Search: %s  Case: %s\n", mode, letterCase))
+	if d.err != nil {
+		s.WriteString(" " + errorStyle.Render(d.err.Error()) + "\n")
+	} else {
+		s.WriteString("\n")
+	}
+	help := []string{
+		"",
+		m.keyHelp("prompt_next_field", "next field", "prompt_regex", "text/regex"),
+		m.keyHelp("prompt_option", "case", "prompt_confirm", "search", "prompt_cancel", "cancel"),
+		"",
+		"Include, Exclude: *.go *_test.go",
+	}
+	for _, line := range help {
+		s.WriteString(" " + trashInfoStyle.Render(line) + "\n")
+	}
+
+	return activeStyle.Width(width).Height(height).Render(s.String())
+}
+
+// jobRows returns the number of jobs shown in the job list.
+func (m model) jobRows() int {
+	return max(1, m.leftPane.height-3)
+}
+
+// batchRenameRows returns the number of files shown in the multi-rename preview.
+func (m model) batchRenameRows() int {
+	return max(1, m.leftPane.height-7)
+}
+
+func (m model) batchRenameView(width, height int) string {
+	b := m.batchRename
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("Multi-Rename %d items  %s\n", len(b.files), m.keyHelp("prompt_next_field", "next field", "prompt_regex", "regex/text", "prompt_option", "case", "prompt_confirm", "rename", "prompt_cancel", "cancel")))
+	labels := [fieldCount]string{"Name:    ", "Search:  ", "Replace: "}
+	for i, label := range labels {
+		if i == b.focus {
+			s.WriteString(" " + label + b.inputs[i].View() + "\n")
+		} else {
+			s.WriteString(" " + label + b.inputs[i].Value() + "\n")
+		}
+	}
+	mode := "regex"
+	if b.literal {
+		mode = "text"
+	}
+	status := fmt.Sprintf(" Search: %s  Case: %s  [N] name [E] extension [C] counter [D] date", mode, b.caseMode)
+	switch {
+	case b.err != nil:
+		status = " " + errorStyle.Render(b.err.Error())
+	case b.problems() > 0:
+		status = " " + errorStyle.Render(fmt.Sprintf("%d names can't be used", b.problems()))
+	}
+	s.WriteString(status + "\n\n")
+
+	column := max(1, (width-6)/2)
+	rows := m.batchRenameRows()
+	for i := b.scrollY; i < len(b.plan) && i < b.scrollY+rows; i++ {
+		r := b.plan[i]
+		line := fmt.Sprintf(" %-*s → ", column, truncateMiddle(r.file.Name, column))
+		newName := truncateMiddle(r.newName, column)
+		switch {
+		case r.problem != "":
+			line += errorStyle.Render(newName + "  " + r.problem)
+		case !r.changed():
+			line += trashInfoStyle.Render(newName)
+		default:
+			line += newName
+		}
+		s.WriteString(line + "\n")
+	}
+
+	return activeStyle.Width(width).Height(height).Render(s.String())
+}
+
+// paletteRows returns the number of commands shown in the command palette.
+func (m model) paletteRows() int {
+	return max(1, m.leftPane.height-4)
+}
+
+func (m model) paletteView(width, height int) string {
+	p := m.palette
+	var s strings.Builder
+	s.WriteString(" Command: " + p.input.View() + "\n")
+	s.WriteString(trashInfoStyle.Render(fmt.Sprintf(" %d of %d commands  up/down:choose %s", len(p.matches), len(p.actions), m.keyHelp("prompt_confirm", "run", "prompt_cancel", "close"))) + "\n")
+
+	labelWidth, keysWidth := 0, 0
+	for _, match := range p.matches {
+		labelWidth = max(labelWidth, lipgloss.Width(match.action.Label))
+		keysWidth = max(keysWidth, lipgloss.Width(strings.Join(match.action.Keys, ", ")))
+	}
+	helpWidth := max(1, width-labelWidth-keysWidth-8)
+	rows := m.paletteRows()
+	for i := p.scrollY; i < len(p.matches) && i < p.scrollY+rows; i++ {
+		a := p.matches[i].action
+		label := fmt.Sprintf("%-*s", labelWidth, a.Label)
+		keys := fmt.Sprintf("%-*s", keysWidth, strings.Join(a.Keys, ", "))
+		help := truncateMiddle(a.Help, helpWidth)
+		if i == p.cursor {
+			s.WriteString(cursorStyle.Render(" "+label+"  "+keys+"  "+help) + "\n")
+			continue
+		}
+		s.WriteString(" " + highlightRunes(label, p.matches[i].positions, lipgloss.NewStyle(), matchStyle) + "  " + hintKeyStyle.UnsetBackground().UnsetPadding().Render(keys) + "  " + trashInfoStyle.Render(help) + "\n")
+	}
+
+	return activeStyle.Width(width).Height(height).Render(s.String())
+}
+
+// highlightRunes renders the runes of s at positions with style and the
+// others with base.
+func highlightRunes(s string, positions []int, base, style lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(s)
+	}
+	var b strings.Builder
+	runes := []rune(s)
+	next := 0
+	for start := 0; start < len(runes); {
+		// Render runs of highlighted and plain runes in one piece
+		highlighted := next < len(positions) && positions[next] == start
+		end := start
+		for end < len(runes) && (next < len(positions) && positions[next] == end) == highlighted {
+			if highlighted {
+				next++
+			}
+			end++
+		}
+		if highlighted {
+			b.WriteString(style.Render(string(runes[start:end])))
+		} else {
+			b.WriteString(base.Render(string(runes[start:end])))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// highlightMatches renders the parts of s matching re with matchStyle.
+func highlightMatches(s string, re *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(s[last:loc[0]] + matchStyle.Render(s[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	return b.String() + s[last:]
+}
+
+// headerView fits the pane's title into width with info on the right. The
+// info is left out when it would leave too little room for the title.
+func headerView(title, info string, width int) string {
+	room := width - lipgloss.Width(info) - 1
+	if room < 10 {
+		return truncateMiddle(title, width)
+	}
+	title = truncateMiddle(title, room)
+	gap := strings.Repeat(" ", width-lipgloss.Width(title)-lipgloss.Width(info))
+	return title + gap + trashInfoStyle.Render(info)
+}
+
+func paneView(p pane) string {
+	var s strings.Builder
+	switch p.listing {
+	case listingTrash:
+		s.WriteString(fmt.Sprintf("Trash (%d items)\n", len(p.files)))
+	case listingFind:
+		info := fmt.Sprintf("%d found", len(p.unfiltered)-1) // Without ".."
+		if p.finder != nil {
+			info += ", searching…"
+		}
+		s.WriteString(headerView("Find "+p.findInfo+" in "+p.path, info, p.width) + "\n")
+	default:
+		info := p.sort.String()
+		if p.hidden > 0 {
+			info = fmt.Sprintf("%d hidden, %s", p.hidden, info)
+		}
+		s.WriteString(headerView(p.path, info, p.width) + "\n")
+	}
+
+	s.WriteString(p.listView(p.width))
+
 	style := inactiveStyle
 	if p.active {
 		style = activeStyle
 	}
 
-	return style.Width(p.width).Height(p.height).Render(s.String())
+	label := p.filterLabel()
+	if label == "" {
+		return style.Width(p.width).Height(p.height).Render(s.String())
+	}
+	// The filter is written into the bottom border
+	body := style.BorderBottom(false).Width(p.width).Height(p.height).Render(s.String())
+	border := style.GetBorderStyle()
+	label = lipgloss.NewStyle().MaxWidth(max(0, p.width-4)).Render(label)
+	fill := strings.Repeat(border.Bottom, max(0, p.width-3-lipgloss.Width(label)))
+	borderStyle := lipgloss.NewStyle().Foreground(style.GetBorderBottomForeground())
+	bottom := borderStyle.Render(border.BottomLeft+border.Bottom+" ") + label + borderStyle.Render(" "+fill+border.BottomRight)
+	return body + "\n" + bottom
+}
+
+// filterLabel describes the pane's filter for its border, or returns "" when
+// there is none.
+func (p pane) filterLabel() string {
+	switch {
+	case p.filterInput != nil:
+		label := fmt.Sprintf("Filter (%s): %s", p.filter.kind, p.filterInput.View())
+		if p.filter.err != nil {
+			label += errorStyle.Render(" invalid")
+		}
+		return label
+	case p.filter.err != nil:
+		return errorStyle.Render(fmt.Sprintf("%s %s: %v", p.filter.kind, p.filter.query, p.filter.err))
+	case p.filter.active():
+		// The ".." entry isn't counted
+		shown, total := len(p.files), len(p.unfiltered)
+		if len(p.files) > 0 && p.files[0].Name == ".." {
+			shown, total = shown-1, total-1
+		}
+		return fmt.Sprintf("%s %s: %d of %d", p.filter.kind, p.filter.query, shown, total)
+	}
+	return ""
 }
 
 func (m model) hintsView() string {
@@ -171,18 +588,59 @@ func (m model) hintsView() string {
 		targetModifier = "shift"
 	}
 
-	for _, shortcut := range m.keyMap.GetShortcuts() {
-		if shortcut.Modifier == targetModifier {
-			hint := hintCardStyle.Render(
-				lipgloss.JoinHorizontal(lipgloss.Left,
-					hintKeyStyle.Render(shortcut.DisplayKey),
-					hintDescStyle.Render(shortcut.Action),
-				),
-			)
-			hints = append(hints, hint)
+	hint := func(key, label string) string {
+		return hintCardStyle.Render(
+			lipgloss.JoinHorizontal(lipgloss.Left,
+				hintKeyStyle.Render(key),
+				hintDescStyle.Render(label),
+			),
+		)
+	}
+
+	// Panes show a few common commands and the custom actions with the keys
+	// using the modifier, and the palette's key for the rest. Other modes show
+	// all of theirs
+	mode := m.keyMode()
+	var actions []*Action
+	var last string
+	if mode == modePane {
+		for _, cmd := range paneHints {
+			if action, ok := m.keyMap.find(cmd); ok {
+				actions = append(actions, action)
+			}
+		}
+		for _, action := range m.keyMap.inMode(modePane) {
+			if _, ok := m.customActions[action.Cmd]; ok {
+				actions = append(actions, action)
+			}
+		}
+		if key := m.keyMap.keyFor("palette"); key != "" {
+			last = hint(key, "Commands")
+		}
+	} else {
+		targetModifier = ""
+		for _, action := range m.keyMap.inMode(mode) {
+			if action.Mode == mode {
+				actions = append(actions, action)
+			}
 		}
 	}
 
+	// Hints that don't fit the width are left out
+	room := m.leftPane.width + m.rightPane.width + 4 - lipgloss.Width(modifiers) - lipgloss.Width(last)
+	for _, action := range actions {
+		if key, ok := action.hintKey(targetModifier); ok {
+			h := hint(key, action.Label)
+			if room -= lipgloss.Width(h); room < 0 {
+				break
+			}
+			hints = append(hints, h)
+		}
+	}
+	if last != "" {
+		hints = append(hints, last)
+	}
+
 	return lipgloss.JoinHorizontal(lipgloss.Center, // Alignment check
 		modifiers,
 		// No spacer needed if margins are handled by styles